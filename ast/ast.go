@@ -1,6 +1,10 @@
 package ast
 
-import "github.com/Neetless/sqlfmt/token"
+import (
+	"sort"
+
+	"github.com/Neetless/sqlfmt/token"
+)
 
 // Node is a base interface which gives position information.
 type Node interface {
@@ -14,6 +18,79 @@ type Stmt interface {
 	stmtNode()
 }
 
+// Comment represents a single -- line comment or /* */ block comment.
+type Comment struct {
+	Slash token.Pos // position of "--" or "/*"
+	Text  string    // comment text including the comment markers
+}
+
+// Pos returns the position of the comment marker.
+func (c *Comment) Pos() token.Pos {
+	return c.Slash
+}
+
+// End returns the position immediately after the comment.
+func (c *Comment) End() token.Pos {
+	return c.Slash + token.Pos(len(c.Text))
+}
+
+// CommentGroup represents a sequence of comments
+// with no other tokens and no empty lines between.
+type CommentGroup struct {
+	List []*Comment // len(List) > 0
+}
+
+// Pos returns the position of the first comment.
+func (g *CommentGroup) Pos() token.Pos {
+	return g.List[0].Pos()
+}
+
+// End returns the end position of the last comment.
+func (g *CommentGroup) End() token.Pos {
+	return g.List[len(g.List)-1].End()
+}
+
+// File represents a sql source file which consists of statements.
+type File struct {
+	Stmts      []Stmt
+	Semicolons []token.Pos     // position of the last ';' after each statement, or NoPos
+	Comments   []*CommentGroup // list of all comments in the source file
+}
+
+// Pos returns the position of the first statement.
+func (f *File) Pos() token.Pos {
+	if len(f.Stmts) == 0 {
+		return 0
+	}
+	return f.Stmts[0].Pos()
+}
+
+// End returns the end position of the last statement.
+func (f *File) End() token.Pos {
+	if len(f.Stmts) == 0 {
+		return 0
+	}
+	return f.Stmts[len(f.Stmts)-1].End()
+}
+
+// BadStmt is a placeholder for statements containing syntax errors
+// for which no correct statement nodes can be created.
+type BadStmt struct {
+	From, To token.Pos // position range of bad statement
+}
+
+func (b BadStmt) stmtNode() {}
+
+// Pos implements Node interface.
+func (b BadStmt) Pos() token.Pos {
+	return b.From
+}
+
+// End implements Node interface.
+func (b BadStmt) End() token.Pos {
+	return b.To
+}
+
 // DataMnpltStmt represents data manipulate statement.
 type DataMnpltStmt struct {
 	Stmt
@@ -22,11 +99,16 @@ type DataMnpltStmt struct {
 // SelectStmt represents a select statement.
 type SelectStmt struct {
 	Begin   token.Pos
+	With    WithClause
 	Select  SelectClause
 	From    FromClause
 	Where   WhereClause
 	Groupby GroupbyClause
+	Having  HavingClause
 	Orderby OrderbyClause
+	Limit   LimitClause
+	Offset  OffsetClause
+	Fetch   FetchClause
 }
 
 func (s SelectStmt) stmtNode() {
@@ -39,8 +121,12 @@ func (s SelectStmt) Pos() token.Pos {
 
 // End is implmentation for Node interface.
 func (s SelectStmt) End() token.Pos {
-	//TODO Last component for SelectStmt is not determined.
+	if end := tailEnd(s.Orderby, s.Limit, s.Offset, s.Fetch); end != token.NoPos {
+		return end
+	}
 	switch {
+	case s.Having.Exists:
+		return s.Having.End()
 	case s.Groupby.Exists:
 		return s.Groupby.End()
 	case s.Where.Exists:
@@ -50,6 +136,134 @@ func (s SelectStmt) End() token.Pos {
 	}
 }
 
+// SetOpStmt represents queries combined by a set operator
+// "X Op [ALL | DISTINCT] Y". X and Y are a SelectStmt, a SetOpStmt or a
+// ParenStmt.
+// The WITH and ORDER BY clauses apply to the whole compound query.
+type SetOpStmt struct {
+	With     WithClause
+	X        Stmt
+	OpPos    token.Pos
+	Op       token.Token // UNION, INTERSECT or EXCEPT
+	All      bool        // ALL is written
+	Distinct bool        // DISTINCT is written
+	Y        Stmt
+	Orderby  OrderbyClause
+	Limit    LimitClause
+	Offset   OffsetClause
+	Fetch    FetchClause
+}
+
+func (s SetOpStmt) stmtNode() {}
+
+// Pos implements Node interface.
+func (s SetOpStmt) Pos() token.Pos {
+	if s.With.Exists {
+		return s.With.Pos()
+	}
+	return s.X.Pos()
+}
+
+// End implements Node interface.
+func (s SetOpStmt) End() token.Pos {
+	if end := tailEnd(s.Orderby, s.Limit, s.Offset, s.Fetch); end != token.NoPos {
+		return end
+	}
+	return s.Y.End()
+}
+
+// ParenStmt represents a parenthesized query. It's used as an operand of
+// a set operator, and may be followed by its own ORDER BY, LIMIT, OFFSET
+// and FETCH clauses when it's the whole statement.
+type ParenStmt struct {
+	With    WithClause
+	Lparen  token.Pos
+	Query   Stmt
+	Rparen  token.Pos
+	Orderby OrderbyClause
+	Limit   LimitClause
+	Offset  OffsetClause
+	Fetch   FetchClause
+}
+
+func (s ParenStmt) stmtNode() {}
+
+// Pos implements Node interface.
+func (s ParenStmt) Pos() token.Pos {
+	if s.With.Exists {
+		return s.With.Pos()
+	}
+	return s.Lparen
+}
+
+// End implements Node interface.
+func (s ParenStmt) End() token.Pos {
+	if end := tailEnd(s.Orderby, s.Limit, s.Offset, s.Fetch); end != token.NoPos {
+		return end
+	}
+	return s.Rparen + 1
+}
+
+// tailEnd returns the end of the last of the clauses ending a query, or
+// NoPos if none of them exists. LIMIT and OFFSET may be written in any
+// order.
+func tailEnd(clauses ...Clause) token.Pos {
+	end := token.NoPos
+	for _, c := range clauses {
+		if e := c.End(); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// WithClause represents a WITH clause defining common table expressions.
+type WithClause struct {
+	Begin     token.Pos
+	Recursive bool
+	CTEs      []*CTE
+	Exists    bool
+}
+
+func (w WithClause) clauseNode() {}
+
+// Pos implements Node interface.
+func (w WithClause) Pos() token.Pos {
+	if !w.Exists {
+		return token.NoPos
+	}
+	return w.Begin
+}
+
+// End implements Node interface.
+func (w WithClause) End() token.Pos {
+	if !w.Exists {
+		return token.NoPos
+	}
+	return w.CTEs[len(w.CTEs)-1].End()
+}
+
+// CTE represents a common table expression
+// "Name [(Cols)] AS [[NOT] MATERIALIZED] (Query)".
+type CTE struct {
+	NamePos         token.Pos
+	Name            string
+	Cols            []Ident // column names; or nil
+	Materialized    bool    // MATERIALIZED is written
+	NotMaterialized bool    // NOT MATERIALIZED is written
+	Query           SubqueryExpr
+}
+
+// Pos implements Node interface.
+func (c *CTE) Pos() token.Pos {
+	return c.NamePos
+}
+
+// End implements Node interface.
+func (c *CTE) End() token.Pos {
+	return c.Query.End()
+}
+
 // Clause represents any clause node.
 type Clause interface {
 	Node
@@ -58,8 +272,11 @@ type Clause interface {
 
 // SelectClause represents select clause for sql.
 type SelectClause struct {
-	Begin token.Pos
-	Cols  []*Column
+	Begin      token.Pos
+	Distinct   bool   // DISTINCT is written
+	DistinctOn []Expr // expressions of DISTINCT ON; or nil
+	Top        Expr   // TOP count; or nil
+	Cols       []*Column
 }
 
 func (s SelectClause) clauseNode() {}
@@ -154,12 +371,119 @@ func (g GroupbyClause) End() token.Pos {
 	return g.Groups[len(g.Groups)-1].End()
 }
 
+// HavingClause represents having clause node.
+type HavingClause struct {
+	Begin    token.Pos
+	CondExpr Expr
+	Exists   bool
+}
+
+func (h HavingClause) clauseNode() {}
+
+// Pos is implementation of Node interface.
+func (h HavingClause) Pos() token.Pos {
+	if !h.Exists {
+		return 0
+	}
+	return h.Begin
+}
+
+// End is implementation of Node interface.
+func (h HavingClause) End() token.Pos {
+	if !h.Exists {
+		return 0
+	}
+	return h.CondExpr.End()
+}
+
+// LimitClause represents "LIMIT Count".
+type LimitClause struct {
+	Begin  token.Pos
+	Count  Expr
+	Exists bool
+}
+
+func (l LimitClause) clauseNode() {}
+
+// Pos is implementation of Node interface.
+func (l LimitClause) Pos() token.Pos {
+	if !l.Exists {
+		return 0
+	}
+	return l.Begin
+}
+
+// End is implementation of Node interface.
+func (l LimitClause) End() token.Pos {
+	if !l.Exists {
+		return 0
+	}
+	return l.Count.End()
+}
+
+// OffsetClause represents "OFFSET Offset [ROW | ROWS]".
+type OffsetClause struct {
+	Begin  token.Pos
+	Offset Expr
+	Rows   string // "ROW" or "ROWS" if written; or ""
+	EndPos token.Pos
+	Exists bool
+}
+
+func (o OffsetClause) clauseNode() {}
+
+// Pos is implementation of Node interface.
+func (o OffsetClause) Pos() token.Pos {
+	if !o.Exists {
+		return 0
+	}
+	return o.Begin
+}
+
+// End is implementation of Node interface.
+func (o OffsetClause) End() token.Pos {
+	if !o.Exists {
+		return 0
+	}
+	return o.EndPos
+}
+
+// FetchClause represents
+// "FETCH {FIRST | NEXT} [Count] {ROW | ROWS} {ONLY | WITH TIES}".
+type FetchClause struct {
+	Begin    token.Pos
+	First    string // "FIRST" or "NEXT"
+	Count    Expr   // number of rows; or nil
+	Rows     string // "ROW" or "ROWS"
+	WithTies bool   // WITH TIES instead of ONLY
+	EndPos   token.Pos
+	Exists   bool
+}
+
+func (f FetchClause) clauseNode() {}
+
+// Pos is implementation of Node interface.
+func (f FetchClause) Pos() token.Pos {
+	if !f.Exists {
+		return 0
+	}
+	return f.Begin
+}
+
+// End is implementation of Node interface.
+func (f FetchClause) End() token.Pos {
+	if !f.Exists {
+		return 0
+	}
+	return f.EndPos
+}
+
 // OrderbyClause represents where clause node.
 type OrderbyClause struct {
 	Node
 	Begin  token.Pos
 	ByPos  token.Pos
-	Orders []Expr
+	Orders []OrderItem
 	Exists bool
 }
 
@@ -184,9 +508,29 @@ func (o OrderbyClause) End() token.Pos {
 	return o.Orders[len(o.Orders)-1].End()
 }
 
+// OrderItem represents an expression of ORDER BY with its sort order,
+// "X [COLLATE Collate] [ASC | DESC] [NULLS {FIRST | LAST}]".
+type OrderItem struct {
+	X          Expr
+	CollatePos token.Pos   // position of COLLATE; or NoPos
+	Collate    string      // collation name; or ""
+	DirPos     token.Pos   // position of ASC or DESC; or NoPos
+	Dir        token.Token // ASC or DESC; or ILLEGAL if omitted
+	NullsPos   token.Pos   // position of NULLS; or NoPos
+	Nulls      string      // "FIRST" or "LAST"; or ""
+	EndPos     token.Pos
+}
+
+// Pos is implementation of Node interface.
+func (o OrderItem) Pos() token.Pos { return o.X.Pos() }
+
+// End is implementation of Node interface.
+func (o OrderItem) End() token.Pos { return o.EndPos }
+
 // Table contains a table factors.
 type Table struct {
 	Value  TableExpr
+	AsPos  token.Pos // position of AS; or NoPos if the alias is implicit or absent
 	Alias  string
 	EndPos token.Pos
 }
@@ -210,8 +554,8 @@ type TableExpr interface {
 // TableBasicLit represents one table name.
 type TableBasicLit struct {
 	Begin token.Pos
-	Kind  token.Token
-	Name  string
+	Kind  token.Token // token.IDENT or token.QUOTED_IDENT
+	Name  string      // table name; quoted names include their quotes
 }
 
 func (t TableBasicLit) tableExprNode() {}
@@ -226,10 +570,48 @@ func (t TableBasicLit) End() token.Pos {
 	return t.Begin + token.Pos(len(t.Name))
 }
 
+// JoinExpr represents a joined table "Left [NATURAL] Kind [OUTER] JOIN
+// Right [ON Cond | USING (Using)]". Joins are left associative, so Left
+// may be a join itself.
+type JoinExpr struct {
+	Left    *Table
+	JoinPos token.Pos   // position of the first keyword of the join
+	Natural bool        // NATURAL join
+	Kind    token.Token // INNER, LEFT, RIGHT, FULL, CROSS, or JOIN for a plain JOIN
+	Outer   bool        // OUTER is written
+	Right   *Table
+
+	OnPos token.Pos // position of ON; or NoPos
+	Cond  Expr      // join condition; or nil
+
+	UsingPos token.Pos // position of USING; or NoPos
+	Using    []Ident   // join columns
+	Rparen   token.Pos // position of ")" closing the join columns
+}
+
+func (j JoinExpr) tableExprNode() {}
+
+// Pos implements Node interface.
+func (j JoinExpr) Pos() token.Pos {
+	return j.Left.Pos()
+}
+
+// End implements Node interface.
+func (j JoinExpr) End() token.Pos {
+	switch {
+	case j.Cond != nil:
+		return j.Cond.End()
+	case j.UsingPos != token.NoPos:
+		return j.Rparen + 1
+	}
+	return j.Right.End()
+}
+
 // Column represents a column of table.
 type Column struct {
 	Node
 	Value  Expr
+	AsPos  token.Pos // position of AS; or NoPos if the alias is implicit or absent
 	Alias  string
 	EndPos token.Pos
 }
@@ -250,10 +632,29 @@ type Expr interface {
 	exprNode()
 }
 
-// IsNullExpr represent is null expression.
+// BadExpr is a placeholder for expressions containing syntax errors
+// for which no correct expression nodes can be created.
+type BadExpr struct {
+	From, To token.Pos // position range of bad expression
+}
+
+func (b BadExpr) exprNode() {}
+
+// Pos implements Node interface.
+func (b BadExpr) Pos() token.Pos {
+	return b.From
+}
+
+// End implements Node interface.
+func (b BadExpr) End() token.Pos {
+	return b.To
+}
+
+// IsNullExpr represent is null expression, "Value IS [NOT] NULL".
 type IsNullExpr struct {
 	Value   Expr
 	IsPos   token.Pos
+	Not     bool // IS NOT NULL
 	NullPos token.Pos
 }
 
@@ -335,11 +736,11 @@ func (e ElseClause) End() token.Pos {
 
 // CallExpr represent function call expression.
 type CallExpr struct {
-	Begin    token.Pos
-	FuncName string
-	Lparen   token.Pos
-	Args     []Expr
-	Rparen   token.Pos
+	Begin  token.Pos
+	Func   Expr // function name; an Ident or a QualifiedName
+	Lparen token.Pos
+	Args   []Expr
+	Rparen token.Pos
 }
 
 func (c CallExpr) exprNode() {}
@@ -374,7 +775,8 @@ func (b BinaryExpr) End() token.Pos {
 	return b.Y.End()
 }
 
-// UnaryExpr represents a unary expression.
+// UnaryExpr represents a unary expression. The EXISTS, ANY, ALL and
+// SOME operators are unary expressions with a SubqueryExpr operand.
 type UnaryExpr struct {
 	OpPos token.Pos
 	Op    token.Token
@@ -412,12 +814,137 @@ func (b BasicLit) End() token.Pos {
 	return b.Begin + token.Pos(len(b.Value))
 }
 
-// Ident represents simple identifier for where clause.
+// SubqueryExpr represents a parenthesized query, a SelectStmt or a
+// SetOpStmt. It's used as a scalar or row subquery, and with an alias
+// as a derived table.
+type SubqueryExpr struct {
+	Lparen token.Pos
+	Query  Stmt
+	Rparen token.Pos
+}
+
+func (s SubqueryExpr) exprNode()      {}
+func (s SubqueryExpr) tableExprNode() {}
+
+// Pos implements Node interface.
+func (s SubqueryExpr) Pos() token.Pos {
+	return s.Lparen
+}
+
+// End implements Node interface.
+func (s SubqueryExpr) End() token.Pos {
+	return s.Rparen + 1
+}
+
+// ParenExpr represents a parenthesized expression.
+type ParenExpr struct {
+	Lparen token.Pos
+	X      Expr
+	Rparen token.Pos
+}
+
+func (p ParenExpr) exprNode() {}
+
+// Pos implements Node interface.
+func (p ParenExpr) Pos() token.Pos {
+	return p.Lparen
+}
+
+// End implements Node interface.
+func (p ParenExpr) End() token.Pos {
+	return p.Rparen + 1
+}
+
+// ListExpr represents a parenthesized list of expressions, such as the
+// values of IN or a row constructor.
+type ListExpr struct {
+	Lparen token.Pos
+	List   []Expr
+	Rparen token.Pos
+}
+
+func (l ListExpr) exprNode() {}
+
+// Pos implements Node interface.
+func (l ListExpr) Pos() token.Pos {
+	return l.Lparen
+}
+
+// End implements Node interface.
+func (l ListExpr) End() token.Pos {
+	return l.Rparen + 1
+}
+
+// InExpr represents "X [NOT] IN Set".
+type InExpr struct {
+	X     Expr
+	Not   bool
+	InPos token.Pos
+	Set   Expr // SubqueryExpr or ListExpr
+}
+
+func (i InExpr) exprNode() {}
+
+// Pos implements Node interface.
+func (i InExpr) Pos() token.Pos {
+	return i.X.Pos()
+}
+
+// End implements Node interface.
+func (i InExpr) End() token.Pos {
+	return i.Set.End()
+}
+
+// ParamStyle is the way a bind parameter is written.
+type ParamStyle int
+
+// The styles of bind parameters.
+const (
+	QuestionParam ParamStyle = iota // ?
+	DollarParam                     // $1
+	ColonParam                      // :name or :1
+	AtParam                         // @name
+)
+
+// Param represents a bind parameter or placeholder.
+type Param struct {
+	Begin token.Pos
+	Style ParamStyle
+	Name  string // name of a named parameter, without its prefix
+	Index int    // index of a numbered parameter; 0 if it has none
+	Value string // parameter as written
+}
+
+func (p Param) exprNode() {}
+
+// Pos implements Node interface.
+func (p Param) Pos() token.Pos {
+	return p.Begin
+}
+
+// End implements Node interface.
+func (p Param) End() token.Pos {
+	return p.Begin + token.Pos(len(p.Value))
+}
+
+// Params returns the bind parameters of node in source order.
+func Params(node Node) []Param {
+	var params []Param
+	Inspect(node, func(n Node) bool {
+		if p, ok := n.(Param); ok {
+			params = append(params, p)
+		}
+		return true
+	})
+	sort.Slice(params, func(i, j int) bool { return params[i].Begin < params[j].Begin })
+	return params
+}
+
+// Ident represents an identifier.
 type Ident struct {
-	TblName string
-	LitPos  token.Pos
-	Kind    token.Token
-	Lit     string
+	LitPos token.Pos
+	Kind   token.Token // token.IDENT or token.QUOTED_IDENT
+	Lit    string      // identifier; quoted identifiers include their quotes
 }
 
 func (i Ident) exprNode() {}
@@ -431,3 +958,24 @@ func (i Ident) Pos() token.Pos {
 func (i Ident) End() token.Pos {
 	return i.LitPos + token.Pos(len(i.Lit))
 }
+
+// QualifiedName represents a name of two or more parts separated by
+// periods, such as "schema.tbl" or "db.schema.tbl.col". It names a
+// table, a column, a function or a type. The last part of a column
+// reference may be "*", an Ident of kind token.ASTA.
+type QualifiedName struct {
+	Parts []Ident
+}
+
+func (q QualifiedName) exprNode()      {}
+func (q QualifiedName) tableExprNode() {}
+
+// Pos implements Node interface.
+func (q QualifiedName) Pos() token.Pos {
+	return q.Parts[0].Pos()
+}
+
+// End implements Node interface.
+func (q QualifiedName) End() token.Pos {
+	return q.Parts[len(q.Parts)-1].End()
+}
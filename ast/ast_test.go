@@ -2,6 +2,8 @@ package ast
 
 import (
 	"testing"
+
+	"github.com/Neetless/sqlfmt/token"
 )
 
 func TestAst(t *testing.T) {
@@ -12,3 +14,32 @@ func TestAst(t *testing.T) {
 	}
 	t.Logf("%T\n", s)
 }
+
+func TestEqual(t *testing.T) {
+	stmt := func(begin token.Pos, col, table string) SelectStmt {
+		return SelectStmt{
+			Begin:  begin,
+			Select: SelectClause{Begin: begin, Cols: []*Column{{Value: Ident{Kind: token.IDENT, Lit: col}}}},
+			From:   FromClause{Begin: begin + 9, Tables: []*Table{{Value: TableBasicLit{Kind: token.IDENT, Name: table}}}},
+		}
+	}
+	if !Equal(stmt(1, "a", "t"), stmt(20, "a", "t")) {
+		t.Errorf("statements differing only in positions must be equal")
+	}
+	if Equal(stmt(1, "a", "t"), stmt(1, "b", "t")) {
+		t.Errorf("statements with different columns must not be equal")
+	}
+	if Equal(stmt(1, "a", "t"), stmt(1, "a", "u")) {
+		t.Errorf("statements with different tables must not be equal")
+	}
+	a := Ident{Kind: token.IDENT, Lit: "a"}
+	if !Equal(ParenExpr{Lparen: 1, X: ParenExpr{X: a}}, a) {
+		t.Errorf("parentheses must be ignored")
+	}
+	if Equal(UnaryExpr{Op: token.SUB, X: ParenExpr{X: a}}, UnaryExpr{Op: token.NOT, X: a}) {
+		t.Errorf("unary expressions with different operators must not be equal")
+	}
+	if Equal(&Comment{Slash: 1, Text: "-- a"}, &Comment{Slash: 1, Text: "-- b"}) {
+		t.Errorf("comments with different text must not be equal")
+	}
+}
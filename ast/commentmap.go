@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"sort"
+
+	"github.com/Neetless/sqlfmt/token"
+)
+
+// A CommentMap maps the start position of an AST node to the list of
+// comment groups associated with it. Nodes starting at the same position,
+// e.g. a column and its expression, share their comments.
+type CommentMap map[token.Pos][]*CommentGroup
+
+func (cmap CommentMap) addComment(pos token.Pos, c *CommentGroup) {
+	cmap[pos] = append(cmap[pos], c)
+}
+
+// NewCommentMap creates a new comment map by associating comment groups
+// of the comments list with the nodes of the AST specified by node.
+//
+// A comment group g is associated with a node n if:
+//
+//   - g starts on the same line as n ends
+//   - otherwise, g starts before n and n is the first node after g
+//   - otherwise, n is the last node before g
+//
+// Comment groups after the last node of a file are associated with the
+// last node. If node has no children, every group is associated with node.
+func NewCommentMap(fset *token.FileSet, node Node, comments []*CommentGroup) CommentMap {
+	cmap := make(CommentMap)
+	if len(comments) == 0 {
+		return cmap
+	}
+
+	var nodes []Node
+	Inspect(node, func(n Node) bool {
+		// A file starts at its first statement; only its statements
+		// get comments.
+		if _, isFile := n.(*File); n != nil && !isFile {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	// Outer nodes come before inner nodes starting at the same position.
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Pos() < nodes[j].Pos()
+	})
+
+	line := func(p token.Pos) int {
+		if f := fset.File(p); f != nil {
+			return f.Line(p)
+		}
+		return 0
+	}
+
+	for _, g := range comments {
+		var prev, next Node
+		for _, n := range nodes {
+			if n.End() <= g.Pos() {
+				if prev == nil || n.End() > prev.End() || n.End() == prev.End() && n.Pos() < prev.Pos() {
+					prev = n
+				}
+			} else if n.Pos() >= g.End() && next == nil {
+				next = n
+			}
+		}
+
+		switch {
+		case prev != nil && line(prev.End()) == line(g.Pos()):
+			cmap.addComment(prev.Pos(), g)
+		case next != nil:
+			cmap.addComment(next.Pos(), g)
+		case prev != nil:
+			cmap.addComment(prev.Pos(), g)
+		default:
+			cmap.addComment(node.Pos(), g)
+		}
+	}
+
+	return cmap
+}
+
+// Comments returns the comment groups associated with node.
+func (cmap CommentMap) Comments(node Node) []*CommentGroup {
+	return cmap[node.Pos()]
+}
+
+// Filter returns a new comment map consisting of only those entries of
+// cmap for which a corresponding node exists in the AST specified by
+// node.
+func (cmap CommentMap) Filter(node Node) CommentMap {
+	umap := make(CommentMap)
+	Inspect(node, func(n Node) bool {
+		if n != nil {
+			if g := cmap[n.Pos()]; len(g) > 0 {
+				umap[n.Pos()] = g
+			}
+		}
+		return true
+	})
+	return umap
+}
+
+// List returns the comment groups of cmap sorted by position.
+func (cmap CommentMap) List() []*CommentGroup {
+	var list []*CommentGroup
+	for _, groups := range cmap {
+		list = append(list, groups...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pos() < list[j].Pos()
+	})
+	return list
+}
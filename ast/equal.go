@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"reflect"
+
+	"github.com/Neetless/sqlfmt/token"
+)
+
+var (
+	posType   = reflect.TypeOf(token.NoPos)
+	parenType = reflect.TypeOf(ParenExpr{})
+)
+
+// Equal reports whether the trees rooted at x and y are identical apart
+// from the positions they record and the parentheses around
+// expressions, which only group them. Comments are compared by their
+// text.
+func Equal(x, y Node) bool {
+	return equal(reflect.ValueOf(x), reflect.ValueOf(y))
+}
+
+// unparen returns the dynamic value of v with any parentheses removed.
+func unparen(v reflect.Value) reflect.Value {
+	for v.IsValid() {
+		switch {
+		case v.Kind() == reflect.Interface && !v.IsNil():
+			v = v.Elem()
+		case v.Type() == parenType:
+			v = v.FieldByName("X")
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+func equal(x, y reflect.Value) bool {
+	x, y = unparen(x), unparen(y)
+	if x.IsValid() != y.IsValid() {
+		return false
+	}
+	if !x.IsValid() {
+		return true
+	}
+	if x.Type() != y.Type() {
+		return false
+	}
+	switch x.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if x.IsNil() || y.IsNil() {
+			return x.IsNil() == y.IsNil()
+		}
+		return equal(x.Elem(), y.Elem())
+
+	case reflect.Slice:
+		if x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !equal(x.Index(i), y.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			if x.Type().Field(i).Type == posType {
+				continue
+			}
+			if !equal(x.Field(i), y.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	if x.Type() == posType {
+		return true
+	}
+	return x.Interface() == y.Interface()
+}
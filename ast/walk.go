@@ -0,0 +1,252 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Neetless/sqlfmt/token"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+func walkExprList(v Visitor, list []Expr) {
+	for _, x := range list {
+		if x != nil {
+			Walk(v, x)
+		}
+	}
+}
+
+// walkQueryTail walks the clauses ending a query in source order. LIMIT
+// and OFFSET may be written in any order.
+func walkQueryTail(v Visitor, orderby OrderbyClause, limit LimitClause, offset OffsetClause, fetch FetchClause) {
+	clauses := []Clause{orderby, limit, offset, fetch}
+	sort.SliceStable(clauses, func(i, j int) bool { return clauses[i].Pos() < clauses[j].Pos() })
+	for _, c := range clauses {
+		if c.Pos() != token.NoPos {
+			Walk(v, c)
+		}
+	}
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil). Clauses which don't exist in the source are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Comments
+	case *Comment:
+		// nothing to do
+
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	// Expressions
+	case BadExpr, BasicLit, Ident, Param:
+		// nothing to do
+
+	case IsNullExpr:
+		Walk(v, n.Value)
+
+	case CaseExpr:
+		if n.HasSwitchKey {
+			Walk(v, n.SwitchKey)
+		}
+		for _, w := range n.Whens {
+			Walk(v, w)
+		}
+		if n.Else.Exists {
+			Walk(v, n.Else)
+		}
+
+	case *WhenClause:
+		Walk(v, n.CondExpr)
+		Walk(v, n.ResultExpr)
+
+	case ElseClause:
+		Walk(v, n.ResultExpr)
+
+	case CallExpr:
+		Walk(v, n.Func)
+		walkExprList(v, n.Args)
+
+	case QualifiedName:
+		for _, x := range n.Parts {
+			Walk(v, x)
+		}
+
+	case BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+
+	case UnaryExpr:
+		Walk(v, n.X)
+
+	case ParenExpr:
+		Walk(v, n.X)
+
+	case SubqueryExpr:
+		Walk(v, n.Query)
+
+	case ListExpr:
+		walkExprList(v, n.List)
+
+	case InExpr:
+		Walk(v, n.X)
+		Walk(v, n.Set)
+
+	// Tables and columns
+	case *Column:
+		Walk(v, n.Value)
+
+	case *Table:
+		Walk(v, n.Value)
+
+	case TableBasicLit:
+		// nothing to do
+
+	case JoinExpr:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		for _, x := range n.Using {
+			Walk(v, x)
+		}
+
+	// Clauses
+	case WithClause:
+		for _, c := range n.CTEs {
+			Walk(v, c)
+		}
+
+	case *CTE:
+		for _, x := range n.Cols {
+			Walk(v, x)
+		}
+		Walk(v, n.Query)
+
+	case SelectClause:
+		walkExprList(v, n.DistinctOn)
+		if n.Top != nil {
+			Walk(v, n.Top)
+		}
+		for _, c := range n.Cols {
+			Walk(v, c)
+		}
+
+	case FromClause:
+		for _, t := range n.Tables {
+			Walk(v, t)
+		}
+
+	case WhereClause:
+		Walk(v, n.CondExpr)
+
+	case GroupbyClause:
+		walkExprList(v, n.Groups)
+
+	case HavingClause:
+		Walk(v, n.CondExpr)
+
+	case OrderbyClause:
+		for _, o := range n.Orders {
+			Walk(v, o)
+		}
+
+	case OrderItem:
+		Walk(v, n.X)
+
+	case LimitClause:
+		Walk(v, n.Count)
+
+	case OffsetClause:
+		Walk(v, n.Offset)
+
+	case FetchClause:
+		if n.Count != nil {
+			Walk(v, n.Count)
+		}
+
+	// Statements
+	case BadStmt:
+		// nothing to do
+
+	case SelectStmt:
+		if n.With.Exists {
+			Walk(v, n.With)
+		}
+		Walk(v, n.Select)
+		Walk(v, n.From)
+		if n.Where.Exists {
+			Walk(v, n.Where)
+		}
+		if n.Groupby.Exists {
+			Walk(v, n.Groupby)
+		}
+		if n.Having.Exists {
+			Walk(v, n.Having)
+		}
+		walkQueryTail(v, n.Orderby, n.Limit, n.Offset, n.Fetch)
+
+	case SetOpStmt:
+		if n.With.Exists {
+			Walk(v, n.With)
+		}
+		Walk(v, n.X)
+		Walk(v, n.Y)
+		walkQueryTail(v, n.Orderby, n.Limit, n.Offset, n.Fetch)
+
+	case ParenStmt:
+		if n.With.Exists {
+			Walk(v, n.With)
+		}
+		Walk(v, n.Query)
+		walkQueryTail(v, n.Orderby, n.Limit, n.Offset, n.Fetch)
+
+	// Files
+	case *File:
+		for _, s := range n.Stmts {
+			Walk(v, s)
+		}
+		// don't walk n.Comments - they are not part
+		// of the statement tree; use a CommentMap
+		// to relate them to nodes
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
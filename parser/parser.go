@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/Neetless/sqlfmt/ast"
 	"github.com/Neetless/sqlfmt/scanner"
@@ -23,15 +25,26 @@ func (e WrongAstTypeError) Error() string {
 type parser struct {
 	scanner scanner.Scanner
 	file    *token.File
+	errors  scanner.ErrorList
+
+	// Comments
+	comments []*ast.CommentGroup
 
 	// Next token
 	pos token.Pos
 	tok token.Token
 	lit string
+
+	nest int // nesting level of subqueries
 }
 
-// ParseFile parse sql statement from given file.
-func ParseFile(fset *token.FileSet, filename string, src interface{}) (ast.Stmt, error) {
+// ParseFile parses the sql statements of the given file and returns
+// them as an ast.File.
+//
+// If syntax errors were found, the result is a partial AST, in which
+// each erroneous statement is replaced by an ast.BadStmt, and the error
+// is a scanner.ErrorList sorted by source position.
+func ParseFile(fset *token.FileSet, filename string, src interface{}) (*ast.File, error) {
 	var text []byte
 	if src != nil {
 		switch s := src.(type) {
@@ -40,8 +53,7 @@ func ParseFile(fset *token.FileSet, filename string, src interface{}) (ast.Stmt,
 		case []byte:
 			text = s
 		default:
-			var stmt ast.Stmt
-			return stmt, fmt.Errorf("src expect string or []byte but got %T", s)
+			return nil, fmt.Errorf("src expect string or []byte but got %T", s)
 		}
 	} else {
 		file, err := os.Open(filename)
@@ -56,74 +68,447 @@ func ParseFile(fset *token.FileSet, filename string, src interface{}) (ast.Stmt,
 		text = src
 		file.Close()
 	}
-	stmt, err := parse(text, filename)
-	if err != nil {
-		return nil, err
-	}
 
-	return stmt, nil
+	return parse(fset, text, filename)
 }
 
-func parse(src []byte, filename string) (ast.Stmt, error) {
+func parse(fset *token.FileSet, src []byte, filename string) (f *ast.File, err error) {
 	var p parser
 	var s scanner.Scanner
 
-	fs := token.NewFileSet()
-	ef := func(pos token.Position, msg string) {
-		fmt.Fprintf(os.Stderr, "Error occured at position: %d. Message: %s.", pos, msg)
+	eh := func(pos token.Position, msg string) {
+		p.errors.Add(pos, msg)
 	}
-	p.file = fs.AddFile(filename, -1, len(src))
+	p.file = fset.AddFile(filename, -1, len(src))
 
-	s.Init(p.file, src, ef, scanner.ScanComments)
+	s.Init(p.file, src, eh, scanner.ScanComments)
 	p.scanner = s
 
-	stmt, err := p.parseStmt()
-	if err != nil {
-		return stmt, err
+	// f collects the statements as they are parsed, so that it holds
+	// the ones before a bailout.
+	f = &ast.File{}
+	defer func() {
+		if e := recover(); e != nil {
+			// resume same panic if it's not a bailout
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+		}
+		f.Comments = p.comments
+		p.errors.RemoveMultiples()
+		err = p.errors.Err()
+	}()
+
+	p.next()
+	p.parseFile(f)
+	return
+}
+
+// ----------------------------------------------------------------------------
+// Error handling
+
+// bailout is used by the parser to abort parsing after too many errors.
+type bailout struct{}
+
+// maxErrors is the number of errors after which parsing stops.
+const maxErrors = 10
+
+func (p *parser) error(pos token.Pos, msg string) {
+	if len(p.errors) >= maxErrors {
+		panic(bailout{})
+	}
+	p.errors.Add(p.file.Position(pos), msg)
+}
+
+func (p *parser) errorExpected(pos token.Pos, msg string) {
+	msg = "expected " + msg
+	if pos == p.pos {
+		// the error happened at the current position;
+		// make the error message more specific
+		switch p.tok {
+		case token.IDENT, token.QUOTED_IDENT, token.INT, token.REAL, token.STRING:
+			msg += ", found " + p.lit
+		default:
+			msg += ", found '" + p.tok.String() + "'"
+		}
+	}
+	p.error(pos, msg)
+}
+
+// stmtEnd is the set of tokens the parser synchronizes with after an
+// error in a statement.
+var stmtEnd = map[token.Token]bool{
+	token.SEMICOLON: true,
+}
+
+// clauseStart is the set of tokens the parser synchronizes with after an
+// error in a clause.
+var clauseStart = map[token.Token]bool{
+	token.FROM:      true,
+	token.WHERE:     true,
+	token.GROUP:     true,
+	token.HAVING:    true,
+	token.ORDER:     true,
+	token.UNION:     true,
+	token.INTERSECT: true,
+	token.EXCEPT:    true,
+	token.SEMICOLON: true,
+}
+
+// clauseWords is the set of contextual keywords which start a clause.
+var clauseWords = []token.Token{token.LIMIT, token.OFFSET, token.FETCH}
+
+// advance consumes tokens until the current token p.tok
+// is in the 'to' set, or token.EOF.
+func (p *parser) advance(to map[token.Token]bool) {
+	for ; p.tok != token.EOF; p.next() {
+		if to[p.tok] {
+			return
+		}
+	}
+}
+
+// atClauseStart reports whether the current token starts a clause or
+// ends the statement.
+func (p *parser) atClauseStart() bool {
+	if clauseStart[p.tok] {
+		return true
+	}
+	for _, tok := range clauseWords {
+		if p.isWord(tok.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// advanceClause consumes tokens until the current token starts a clause
+// or ends the statement, or token.EOF.
+func (p *parser) advanceClause() {
+	for p.tok != token.EOF && !p.atClauseStart() {
+		p.next()
+	}
+}
+
+// syncClause checks that the current token starts a clause or ends the
+// statement. Otherwise it reports an error naming the clauses which may
+// follow and skips to the next clause.
+func (p *parser) syncClause(next ...token.Token) {
+	if p.tok == token.EOF || p.atClauseStart() || p.tok == token.RPAREN && p.nest > 0 {
+		return
+	}
+	var expected []string
+	for _, tok := range next {
+		expected = append(expected, "'"+tok.String()+"'")
 	}
-	return stmt, nil
+	p.errorExpected(p.pos, strings.Join(expected, ", ")+" or ';'")
+	p.advanceClause()
 }
 
-func (p *parser) parseStmt() (ast.Stmt, error) {
+// isWord reports whether the current token is the identifier word, which
+// is a keyword only in the context where it is checked.
+func (p *parser) isWord(word string) bool {
+	return p.tok == token.IDENT && strings.EqualFold(p.lit, word)
+}
+
+// expectWord is like expect for the contextual keyword word. It returns
+// the upper case word if it is present, or "".
+func (p *parser) expectWord(word string) string {
+	if !p.isWord(word) {
+		return ""
+	}
 	p.next()
+	return strings.ToUpper(word)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
 
+// parseFile parses the statements of the source into f.
+func (p *parser) parseFile(f *ast.File) {
+	for p.tok != token.EOF {
+		// Empty statements are allowed.
+		if p.tok == token.SEMICOLON {
+			if n := len(f.Semicolons); n > 0 {
+				f.Semicolons[n-1] = p.pos
+			}
+			p.next()
+			continue
+		}
+		f.Stmts = append(f.Stmts, p.parseStmt())
+		f.Semicolons = append(f.Semicolons, token.NoPos)
+
+		if p.tok != token.EOF && p.tok != token.SEMICOLON {
+			p.errorExpected(p.pos, "';'")
+			p.advance(stmtEnd)
+		}
+	}
+}
+
+// parseStmt parses a statement. A statement containing syntax errors is
+// returned as an ast.BadStmt.
+func (p *parser) parseStmt() ast.Stmt {
+	pos := p.pos
+	nerrors := len(p.errors)
+
+	var stmt ast.Stmt
 	switch p.tok {
-	case token.SELECT:
-		stmt := ast.SelectStmt{
-			Begin: p.pos,
+	case token.WITH, token.SELECT, token.LPAREN:
+		stmt = p.parseQuery()
+	default:
+		p.errorExpected(pos, "statement")
+		p.advance(stmtEnd)
+	}
+
+	if len(p.errors) > nerrors {
+		return ast.BadStmt{From: pos, To: p.pos}
+	}
+	return stmt
+}
+
+// parseQuery parses a select statement or a compound query combining
+// select statements by set operators.
+func (p *parser) parseQuery() ast.Stmt {
+	begin := p.pos
+	with := p.parseWith()
+	query := p.parseSetOp(1)
+
+	orderby := p.parseOrderby()
+	p.syncClause(token.LIMIT, token.OFFSET, token.FETCH)
+	limit, offset, fetch := p.parseLimits()
+
+	switch q := query.(type) {
+	case ast.SelectStmt:
+		q.Begin = begin
+		q.With = with
+		q.Orderby = orderby
+		q.Limit, q.Offset, q.Fetch = limit, offset, fetch
+		return q
+	case ast.SetOpStmt:
+		q.With = with
+		q.Orderby = orderby
+		q.Limit, q.Offset, q.Fetch = limit, offset, fetch
+		return q
+	case ast.ParenStmt:
+		q.With = with
+		q.Orderby = orderby
+		q.Limit, q.Offset, q.Fetch = limit, offset, fetch
+		return q
+	}
+	return query
+}
+
+// parseLimits parses the LIMIT, OFFSET and FETCH clauses, which may be
+// written in any order after ORDER BY.
+func (p *parser) parseLimits() (limit ast.LimitClause, offset ast.OffsetClause, fetch ast.FetchClause) {
+	for {
+		switch {
+		case p.isWord(token.LIMIT.String()) && !limit.Exists:
+			limit = p.parseLimit()
+		case p.isWord(token.OFFSET.String()) && !offset.Exists:
+			offset = p.parseOffset()
+		case p.isWord(token.FETCH.String()) && !fetch.Exists:
+			fetch = p.parseFetch()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseLimit() ast.LimitClause {
+	pos := p.pos
+	p.next() // LIMIT
+	return ast.LimitClause{Begin: pos, Count: p.parseExpr(), Exists: true}
+}
+
+func (p *parser) parseOffset() ast.OffsetClause {
+	pos := p.pos
+	p.next() // OFFSET
+	clus := ast.OffsetClause{Begin: pos, Offset: p.parseExpr(), Exists: true}
+	clus.EndPos = clus.Offset.End()
+	if p.isWord("ROW") || p.isWord("ROWS") {
+		clus.Rows = strings.ToUpper(p.lit)
+		clus.EndPos = p.pos + token.Pos(len(p.lit))
+		p.next()
+	}
+	return clus
+}
+
+func (p *parser) parseFetch() ast.FetchClause {
+	pos := p.pos
+	p.next() // FETCH
+	clus := ast.FetchClause{Begin: pos, Exists: true}
+	if p.isWord("FIRST") || p.isWord("NEXT") {
+		clus.First = strings.ToUpper(p.lit)
+		p.next()
+	} else {
+		p.errorExpected(p.pos, "'FIRST' or 'NEXT' after FETCH")
+	}
+	if !p.isWord("ROW") && !p.isWord("ROWS") {
+		clus.Count = p.parseExpr()
+	}
+	if p.isWord("ROW") || p.isWord("ROWS") {
+		clus.Rows = strings.ToUpper(p.lit)
+		p.next()
+	} else {
+		p.errorExpected(p.pos, "'ROW' or 'ROWS'")
+	}
+	clus.EndPos = p.pos + token.Pos(len(p.lit))
+	if p.expect(token.WITH) {
+		clus.EndPos = p.pos + token.Pos(len(p.lit))
+		clus.WithTies = p.expectWord("TIES") != ""
+		if !clus.WithTies {
+			p.errorExpected(p.pos, "'TIES' after WITH")
+		}
+	} else if p.expectWord("ONLY") == "" {
+		p.errorExpected(p.pos, "'ONLY' or 'WITH TIES'")
+	}
+	return clus
+}
+
+// setOpPrec returns the precedence of the set operator tok, or 0 if tok
+// is not a set operator. INTERSECT binds tighter than UNION and EXCEPT.
+func setOpPrec(tok token.Token) int {
+	switch tok {
+	case token.UNION, token.EXCEPT:
+		return 1
+	case token.INTERSECT:
+		return 2
+	}
+	return 0
+}
+
+func (p *parser) parseSetOp(prec1 int) ast.Stmt {
+	x := p.parseSetOperand()
+	for {
+		prec := setOpPrec(p.tok)
+		if prec < prec1 {
+			return x
+		}
+		setop := ast.SetOpStmt{X: x, OpPos: p.pos, Op: p.tok}
+		p.next()
+		setop.All = p.expectWord(token.ALL.String()) != ""
+		if !setop.All {
+			setop.Distinct = p.expect(token.DISTINCT)
 		}
-		slctstmt := p.parseSelect()
-		stmt.Select = slctstmt
+		setop.Y = p.parseSetOp(prec + 1)
+		x = setop
+	}
+}
 
-		from := p.parseFrom()
-		stmt.From = from
+// parseSetOperand parses a select statement, or a parenthesized query
+// with its parentheses kept.
+func (p *parser) parseSetOperand() ast.Stmt {
+	if p.tok != token.LPAREN {
+		return p.parseSelectStmt()
+	}
+	lparen := p.pos
+	p.next()
+	p.nest++
+	query := p.parseQuery()
+	p.nest--
+	rparen := p.pos
+	p.mustExpect(token.RPAREN, "')' to close query")
+	return ast.ParenStmt{Lparen: lparen, Query: query, Rparen: rparen}
+}
 
-		where := p.parseWhere()
-		stmt.Where = where
+// parseSelectStmt parses a select statement up to its HAVING clause.
+// The WITH, ORDER BY, LIMIT, OFFSET and FETCH clauses are parsed by
+// parseQuery.
+func (p *parser) parseSelectStmt() ast.SelectStmt {
+	stmt := ast.SelectStmt{
+		Begin: p.pos,
+	}
 
-		groupby := p.parseGroupby()
-		stmt.Groupby = groupby
+	slctstmt := p.parseSelect()
+	stmt.Select = slctstmt
 
-		orderby := p.parseOrderby()
-		stmt.Orderby = orderby
+	from := p.parseFrom()
+	stmt.From = from
+	p.syncClause(token.WHERE, token.GROUP, token.HAVING, token.ORDER, token.LIMIT, token.OFFSET, token.FETCH)
 
-		return stmt, nil
+	where := p.parseWhere()
+	stmt.Where = where
+	p.syncClause(token.GROUP, token.HAVING, token.ORDER, token.LIMIT, token.OFFSET, token.FETCH)
 
-	default:
-		var stmt ast.Stmt
-		return stmt, fmt.Errorf("cannot parsed")
+	groupby := p.parseGroupby()
+	stmt.Groupby = groupby
+	p.syncClause(token.HAVING, token.ORDER, token.LIMIT, token.OFFSET, token.FETCH)
+
+	stmt.Having = p.parseHaving()
+	p.syncClause(token.ORDER, token.LIMIT, token.OFFSET, token.FETCH)
+
+	return stmt
+}
+
+func (p *parser) parseWith() ast.WithClause {
+	pos := p.pos
+	if !p.expect(token.WITH) {
+		return ast.WithClause{Exists: false}
+	}
+	clus := ast.WithClause{Begin: pos, Exists: true}
+	if p.isWord(token.RECURSIVE.String()) {
+		// "WITH recursive AS (...)" names a common table expression
+		if next := p.scanner.Peek(); next == token.IDENT || next == token.QUOTED_IDENT {
+			clus.Recursive = true
+			p.next()
+		}
 	}
+	for {
+		clus.CTEs = append(clus.CTEs, p.parseCTE())
+		if !p.expect(token.COMMA) {
+			break
+		}
+	}
+	return clus
+}
 
+func (p *parser) parseCTE() *ast.CTE {
+	cte := &ast.CTE{NamePos: p.pos, Name: p.lit}
+	p.mustExpectIdent("common table expression name")
+	if p.expect(token.LPAREN) {
+		cte.Cols = p.parseIdentList()
+		p.mustExpect(token.RPAREN, "')' to close column list")
+	}
+	p.mustExpect(token.ALIAS, "'AS'")
+	if p.expect(token.NOT) {
+		cte.NotMaterialized = true
+		if p.expectWord(token.MATERIALIZED.String()) == "" {
+			p.errorExpected(p.pos, "'MATERIALIZED' after NOT")
+		}
+	} else {
+		cte.Materialized = p.expectWord(token.MATERIALIZED.String()) != ""
+	}
+	cte.Query = p.parseSubquery()
+	return cte
 }
 
 func (p *parser) parseSelect() ast.SelectClause {
 	pos := p.pos
 	if !p.expect(token.SELECT) {
-		panic("select token expected but given " + p.tok.String())
+		p.errorExpected(pos, "'SELECT'")
 	}
 
-	cols := p.parseColumns()
-	return ast.SelectClause{Begin: pos, Cols: cols}
+	clus := ast.SelectClause{Begin: pos}
+	if p.expect(token.DISTINCT) {
+		clus.Distinct = true
+		if p.expect(token.ON) {
+			p.mustExpect(token.LPAREN, "'(' after DISTINCT ON")
+			clus.DistinctOn = p.parseExprList()
+			p.mustExpect(token.RPAREN, "')' to close DISTINCT ON")
+		}
+	}
+	if p.isWord(token.TOP.String()) {
+		// "SELECT top, ..." selects a column named top
+		switch p.scanner.Peek() {
+		case token.INT, token.PARAM, token.LPAREN:
+			p.next()
+			clus.Top = p.parsePrimaryExpr()
+		}
+	}
+
+	clus.Cols = p.parseColumns()
+	return clus
 }
 
 // expect check check if the current token is same as expected.
@@ -136,10 +521,32 @@ func (p *parser) expect(tok token.Token) bool {
 	return false
 }
 
+// mustExpect is like expect, but reports an error described by msg if
+// the current token is not tok.
+func (p *parser) mustExpect(tok token.Token, msg string) bool {
+	if p.expect(tok) {
+		return true
+	}
+	p.errorExpected(p.pos, msg)
+	return false
+}
+
+// mustExpectIdent is like mustExpect for a bare or quoted identifier.
+func (p *parser) mustExpectIdent(msg string) bool {
+	if p.expect(token.QUOTED_IDENT) {
+		return true
+	}
+	return p.mustExpect(token.IDENT, msg)
+}
+
 func (p *parser) parseFrom() ast.FromClause {
+	if p.tok != token.FROM {
+		p.errorExpected(p.pos, "'FROM'")
+		p.advanceClause()
+	}
 	pos := p.pos
 	if !p.expect(token.FROM) {
-		panic("from keyword is expected but get " + p.tok.String())
+		return ast.FromClause{Begin: pos}
 	}
 
 	tables := p.parseTableList()
@@ -148,47 +555,163 @@ func (p *parser) parseFrom() ast.FromClause {
 
 func (p *parser) parseTableList() []*ast.Table {
 	var tables []*ast.Table
-L:
 	for {
-		switch p.tok {
-		case token.COMMA:
-			p.next()
-			continue
-		case token.WHERE, token.EOF, token.SEMICOLON, token.GROUP, token.ORDER:
-			break L
-		default:
-			tbl := p.parseTable()
-			tables = append(tables, &tbl)
+		tbl := p.parseTable()
+		tables = append(tables, &tbl)
+		if !p.expect(token.COMMA) {
+			break
 		}
 	}
 	return tables
 }
 
+// parseTable parses a table followed by any number of joins.
 func (p *parser) parseTable() ast.Table {
+	tbl := p.parseTablePrimary()
+	for p.isJoinStart() {
+		left := tbl
+		join := p.parseJoin(&left)
+		tbl = ast.Table{Value: join, EndPos: join.End()}
+	}
+	return tbl
+}
+
+func (p *parser) isJoinStart() bool {
+	switch p.tok {
+	case token.JOIN, token.INNER, token.CROSS, token.NATURAL:
+		return true
+	}
+	return p.outerJoinKind() != token.ILLEGAL
+}
+
+// outerJoinKind returns LEFT, RIGHT or FULL if the current token is that
+// word followed by JOIN or OUTER, or ILLEGAL otherwise. Elsewhere the
+// words are identifiers, such as the functions left and right.
+func (p *parser) outerJoinKind() token.Token {
+	for _, kind := range []token.Token{token.LEFT, token.RIGHT, token.FULL} {
+		if p.isWord(kind.String()) {
+			if next := p.scanner.Peek(); next == token.JOIN || next == token.OUTER {
+				return kind
+			}
+			break
+		}
+	}
+	return token.ILLEGAL
+}
+
+// parseJoin parses a join of left with the following table.
+func (p *parser) parseJoin(left *ast.Table) ast.JoinExpr {
+	join := ast.JoinExpr{Left: left, JoinPos: p.pos, Kind: token.JOIN}
+	join.Natural = p.expect(token.NATURAL)
+	switch kind := p.outerJoinKind(); {
+	case p.tok == token.INNER, p.tok == token.CROSS:
+		join.Kind = p.tok
+		p.next()
+	case kind != token.ILLEGAL:
+		join.Kind = kind
+		p.next()
+		join.Outer = p.expect(token.OUTER)
+	}
+	p.mustExpect(token.JOIN, "'JOIN'")
+
+	right := p.parseTablePrimary()
+	join.Right = &right
+	if join.Natural || join.Kind == token.CROSS {
+		return join
+	}
+
+	switch p.tok {
+	case token.ON:
+		join.OnPos = p.pos
+		p.next()
+		join.Cond = p.parseExpr()
+	case token.USING:
+		join.UsingPos = p.pos
+		p.next()
+		p.mustExpect(token.LPAREN, "'(' after USING")
+		join.Using = p.parseIdentList()
+		join.Rparen = p.pos
+		p.mustExpect(token.RPAREN, "')' to close USING")
+	}
+	return join
+}
+
+// parseIdentList parses a comma separated list of column names.
+func (p *parser) parseIdentList() []ast.Ident {
+	var list []ast.Ident
+	for {
+		pos, kind, lit := p.pos, p.tok, p.lit
+		if !p.mustExpectIdent("column name") {
+			break
+		}
+		list = append(list, ast.Ident{LitPos: pos, Kind: kind, Lit: lit})
+		if !p.expect(token.COMMA) {
+			break
+		}
+	}
+	return list
+}
+
+// parseTablePrimary parses a table name and its alias.
+func (p *parser) parseTablePrimary() ast.Table {
 	expr := p.parseTableExpr()
-	alias := ""
-	endPos := expr.End()
-	// TODO implement implicit alias
-	if p.expect(token.ALIAS) {
+	tbl := ast.Table{Value: expr}
+	tbl.AsPos, tbl.Alias, tbl.EndPos = p.parseAlias(expr.End())
+	if _, ok := expr.(ast.SubqueryExpr); ok && tbl.Alias == "" {
+		p.errorExpected(p.pos, "alias name for derived table")
+	}
+	return tbl
+}
+
+// parseAlias parses the alias of a column or a table, which follows AS
+// or, if AS is omitted, directly the column or table ending at end. It
+// returns the position of AS, the alias name and the end of the aliased
+// column or table.
+func (p *parser) parseAlias(end token.Pos) (asPos token.Pos, alias string, aliasEnd token.Pos) {
+	switch {
+	case p.tok == token.ALIAS:
+		asPos = p.pos
+		p.next()
 		alias = p.lit
-		endPos = p.pos + token.Pos(len(alias))
+		end = p.pos + token.Pos(len(p.lit))
+		p.mustExpectIdent("alias name after AS")
+	case p.tok == token.QUOTED_IDENT, p.tok == token.IDENT && token.CanOmitAs(p.lit):
+		alias = p.lit
+		end = p.pos + token.Pos(len(p.lit))
 		p.next()
 	}
-	return ast.Table{Value: expr, Alias: alias, EndPos: endPos}
+	return asPos, alias, end
 }
 
 func (p *parser) parseTableExpr() ast.TableExpr {
 	switch p.tok {
-	case token.IDENT:
+	case token.IDENT, token.QUOTED_IDENT:
 		begin := p.pos
-		kind := p.tok
-		name := p.lit
-		p.next()
-		return ast.TableBasicLit{Begin: begin, Kind: kind, Name: name}
+		switch name := p.parseName(false).(type) {
+		case ast.Ident:
+			return ast.TableBasicLit{Begin: begin, Kind: name.Kind, Name: name.Lit}
+		case ast.QualifiedName:
+			return name
+		}
+		p.skipBad()
+		return ast.TableBasicLit{Begin: begin}
+	case token.LPAREN:
+		return p.parseSubquery()
+	default:
+		pos := p.pos
+		p.errorExpected(pos, "table name")
+		p.skipBad()
+		return ast.TableBasicLit{Begin: pos}
+	}
+}
+
+// skipBad consumes the offending token unless it's one the parser
+// synchronizes with, so that the parser keeps making progress.
+func (p *parser) skipBad() {
+	switch {
+	case p.tok == token.EOF, p.tok == token.COMMA, p.tok == token.RPAREN, p.atClauseStart():
 	default:
-		// TODO mock return
 		p.next()
-		return ast.TableBasicLit{}
 	}
 }
 
@@ -211,74 +734,97 @@ func (p *parser) parseGroupby() ast.GroupbyClause {
 	if !exist {
 		return ast.GroupbyClause{Exists: false}
 	}
-	if !p.expect(token.BY) {
-		panic("parser expect BY token. but got " + p.tok.String())
-	}
-	clus := ast.GroupbyClause{Begin: pos, ByPos: p.pos, Exists: true}
-	var groups []ast.Expr
-L:
-	for {
-		switch p.tok {
-		case token.EOF, token.ORDER:
-			p.next()
-			break L
-		case token.COMMA:
-			p.next()
-			continue
-		default:
-			groups = append(groups, p.parseExpr())
-		}
-	}
-
-	clus.Groups = groups
+	byPos := p.pos
+	p.mustExpect(token.BY, "'BY' after GROUP")
+	clus := ast.GroupbyClause{Begin: pos, ByPos: byPos, Exists: true}
+	clus.Groups = p.parseExprList()
 	return clus
 }
 
+func (p *parser) parseHaving() ast.HavingClause {
+	pos := p.pos
+	if !p.expect(token.HAVING) {
+		return ast.HavingClause{Exists: false}
+	}
+	return ast.HavingClause{Begin: pos, CondExpr: p.parseExpr(), Exists: true}
+}
+
 func (p *parser) parseOrderby() ast.OrderbyClause {
 	pos := p.pos
 	exist := p.expect(token.ORDER)
 	if !exist {
 		return ast.OrderbyClause{Exists: false}
 	}
-	if !p.expect(token.BY) {
-		panic("parser expect BY token. but got " + p.tok.String())
-	}
-	clus := ast.OrderbyClause{Begin: pos, ByPos: p.pos, Exists: true}
-	var orders []ast.Expr
-L:
+	byPos := p.pos
+	p.mustExpect(token.BY, "'BY' after ORDER")
+	clus := ast.OrderbyClause{Begin: pos, ByPos: byPos, Exists: true}
 	for {
+		clus.Orders = append(clus.Orders, p.parseOrderItem())
+		if !p.expect(token.COMMA) {
+			break
+		}
+	}
+	return clus
+}
+
+func (p *parser) parseOrderItem() ast.OrderItem {
+	item := ast.OrderItem{X: p.parseExpr()}
+	item.EndPos = item.X.End()
+	pos := p.pos
+	if p.expect(token.COLLATE) {
+		item.CollatePos = pos
 		switch p.tok {
-		case token.EOF:
+		case token.IDENT, token.QUOTED_IDENT, token.STRING:
+			item.Collate = p.lit
+			item.EndPos = p.pos + token.Pos(len(p.lit))
 			p.next()
-			break L
-		case token.COMMA:
-			p.next()
-			continue
 		default:
-			orders = append(orders, p.parseExpr())
+			p.errorExpected(p.pos, "collation name after COLLATE")
 		}
 	}
+	if p.tok == token.ASC || p.tok == token.DESC {
+		item.DirPos = p.pos
+		item.Dir = p.tok
+		item.EndPos = p.pos + token.Pos(len(p.lit))
+		p.next()
+	}
+	pos = p.pos
+	if p.expectWord("NULLS") != "" {
+		item.NullsPos = pos
+		if p.isWord("FIRST") || p.isWord("LAST") {
+			item.Nulls = strings.ToUpper(p.lit)
+			item.EndPos = p.pos + token.Pos(len(p.lit))
+			p.next()
+		} else {
+			p.errorExpected(p.pos, "'FIRST' or 'LAST' after NULLS")
+		}
+	}
+	return item
+}
 
-	clus.Orders = orders
-	return clus
+// parseExprList parses a comma separated list of expressions.
+func (p *parser) parseExprList() []ast.Expr {
+	var list []ast.Expr
+	for {
+		list = append(list, p.parseExpr())
+		if !p.expect(token.COMMA) {
+			break
+		}
+	}
+	return list
 }
+
 func (p *parser) parseExpr() ast.Expr {
 	return p.parseBinaryExpr(token.LowestPrec + 1)
 }
 
 func (p *parser) parseColumns() []*ast.Column {
 	var cols []*ast.Column
-
-L:
 	for {
-		switch p.tok {
-		case token.COMMA:
-			p.next()
-		case token.FROM:
-			break L
-		default:
-			col := p.parseColumn()
-			cols = append(cols, &col)
+		col := p.parseColumn()
+		cols = append(cols, &col)
+		if !p.expect(token.COMMA) {
+			break
 		}
 	}
 	return cols
@@ -286,30 +832,30 @@ L:
 
 func (p *parser) parseColumn() ast.Column {
 	expr := p.parseExpr()
-	alias := ""
-	var endPos token.Pos
-	if p.expect(token.ALIAS) {
-		alias = p.lit
-		endPos = p.pos + token.Pos(len(alias))
-		p.next()
-	} else {
-		endPos = expr.End()
-	}
-	return ast.Column{Value: expr, Alias: alias, EndPos: endPos}
+	col := ast.Column{Value: expr}
+	col.AsPos, col.Alias, col.EndPos = p.parseAlias(expr.End())
+	return col
 }
 
 func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
 	x := p.parseUnaryExpr()
-	maybeIsPos := p.pos
-	if p.expect(token.IS) {
-		nullPos := p.pos
-		if p.expect(token.NULL) {
-			x = ast.IsNullExpr{Value: x, IsPos: maybeIsPos, NullPos: nullPos}
-		} else {
-			panic("parser expecte NULL token but got " + p.tok.String())
-		}
-	}
 	for {
+		if p.tok == token.IS {
+			// IS NULL is not associative: a IS NULL IS NULL is an error
+			if _, isNull := x.(ast.IsNullExpr); isNull || token.IsNullPrec < prec1 {
+				return x
+			}
+			x = p.parseIsNullExpr(x)
+			continue
+		}
+		if p.tok == token.IN || p.tok == token.NOT {
+			// IN is not associative either
+			if _, isIn := x.(ast.InExpr); isIn || token.InPrec < prec1 {
+				return x
+			}
+			x = p.parseInExpr(x)
+			continue
+		}
 		op, opPrec := p.tokPrec()
 		if opPrec < prec1 {
 			return x
@@ -317,11 +863,49 @@ func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
 		pos := p.pos
 
 		p.expect(op)
-		y := p.parseBinaryExpr(opPrec + 1)
+		var y ast.Expr
+		if opPrec == token.EQL.Precedence() && p.quantifier() != token.ILLEGAL {
+			y = p.parseQuantifiedExpr()
+		} else {
+			y = p.parseBinaryExpr(opPrec + 1)
+		}
 		x = ast.BinaryExpr{X: x, OpPos: pos, Op: op, Y: y}
 	}
 }
 
+func (p *parser) parseIsNullExpr(x ast.Expr) ast.Expr {
+	isPos := p.pos
+	p.expect(token.IS)
+	not := p.expect(token.NOT)
+	nullPos := p.pos
+	if !p.mustExpect(token.NULL, "'NULL' after IS") {
+		return ast.BadExpr{From: x.Pos(), To: p.pos}
+	}
+	return ast.IsNullExpr{Value: x, IsPos: isPos, Not: not, NullPos: nullPos}
+}
+
+// quantifier returns ANY, ALL or SOME if the current token is that word
+// followed by '(', or ILLEGAL otherwise. The words quantify a subquery
+// only after a comparison operator.
+func (p *parser) quantifier() token.Token {
+	for _, q := range []token.Token{token.ANY, token.ALL, token.SOME} {
+		if p.isWord(q.String()) {
+			if p.scanner.Peek() == token.LPAREN {
+				return q
+			}
+			break
+		}
+	}
+	return token.ILLEGAL
+}
+
+// parseQuantifiedExpr parses "{ANY | ALL | SOME} (subquery)".
+func (p *parser) parseQuantifiedExpr() ast.Expr {
+	pos, op := p.pos, p.quantifier()
+	p.next()
+	return ast.UnaryExpr{OpPos: pos, Op: op, X: p.parseSubquery()}
+}
+
 func (p *parser) parseUnaryExpr() ast.Expr {
 	switch p.tok {
 	case token.ADD, token.SUB:
@@ -333,17 +917,139 @@ func (p *parser) parseUnaryExpr() ast.Expr {
 		pos := p.pos
 		p.next()
 		return ast.BasicLit{Begin: pos, Value: "*", Kind: token.ASTA}
+	case token.NOT:
+		pos := p.pos
+		p.next()
+		x := p.parseBinaryExpr(token.NotPrec + 1)
+		return ast.UnaryExpr{OpPos: pos, Op: token.NOT, X: x}
+	case token.EXISTS:
+		pos, op := p.pos, p.tok
+		p.next()
+		return ast.UnaryExpr{OpPos: pos, Op: op, X: p.parseSubquery()}
 	case token.CASE:
-		return p.parseCaseExpr()
+		return p.parseCastExpr(p.parseCaseExpr())
 	}
-	return p.parsePrimaryExpr()
+	return p.parseCastExpr(p.parsePrimaryExpr())
 }
 
-func (p *parser) parseCaseExpr() ast.CaseExpr {
-	begin := p.pos
-	if !p.expect(token.CASE) {
-		panic("parser expects CASE token. but got " + p.tok.String())
+// parseParam returns the bind parameter lit scanned at pos.
+func parseParam(pos token.Pos, lit string) ast.Param {
+	param := ast.Param{Begin: pos, Value: lit}
+	switch lit[0] {
+	case '?':
+		param.Style = ast.QuestionParam
+	case '$':
+		param.Style = ast.DollarParam
+	case ':':
+		param.Style = ast.ColonParam
+	case '@':
+		param.Style = ast.AtParam
+	}
+	if i, err := strconv.Atoi(lit[1:]); err == nil {
+		param.Index = i
+	} else if len(lit) > 1 {
+		param.Name = lit[1:]
+	}
+	return param
+}
+
+// parseInExpr parses "[NOT] IN (subquery)" or "[NOT] IN (list)" after x.
+func (p *parser) parseInExpr(x ast.Expr) ast.Expr {
+	in := ast.InExpr{X: x, Not: p.expect(token.NOT), InPos: p.pos}
+	if !p.mustExpect(token.IN, "'IN'") {
+		return ast.BadExpr{From: x.Pos(), To: p.pos}
+	}
+	if p.tok != token.LPAREN {
+		p.errorExpected(p.pos, "'(' after IN")
+		return ast.BadExpr{From: x.Pos(), To: p.pos}
+	}
+	lparen := p.pos
+	p.next()
+	if isQueryStart(p.tok) {
+		in.Set = p.parseSubqueryBody(lparen)
+		return in
 	}
+	list := p.parseExprList()
+	rparen := p.pos
+	if !p.mustExpect(token.RPAREN, "')' to close IN list") {
+		return ast.BadExpr{From: x.Pos(), To: p.pos}
+	}
+	in.Set = ast.ListExpr{Lparen: lparen, List: list, Rparen: rparen}
+	return in
+}
+
+func isQueryStart(tok token.Token) bool {
+	return tok == token.SELECT || tok == token.WITH
+}
+
+// parseSubquery parses a parenthesized SELECT statement.
+func (p *parser) parseSubquery() ast.SubqueryExpr {
+	lparen := p.pos
+	p.mustExpect(token.LPAREN, "'(' before subquery")
+	return p.parseSubqueryBody(lparen)
+}
+
+// parseSubqueryBody parses the SELECT statement of a subquery and its
+// closing parenthesis. The opening parenthesis at lparen has been
+// consumed already.
+func (p *parser) parseSubqueryBody(lparen token.Pos) ast.SubqueryExpr {
+	p.nest++
+	query := p.parseQuery()
+	p.nest--
+	rparen := p.pos
+	p.mustExpect(token.RPAREN, "')' to close subquery")
+	return ast.SubqueryExpr{Lparen: lparen, Query: query, Rparen: rparen}
+}
+
+// parseName parses an identifier, or a qualified name if periods follow
+// it. If star is set, the last part of a qualified name may be '*'. The
+// current token must be an identifier.
+func (p *parser) parseName(star bool) ast.Expr {
+	x := ast.Ident{LitPos: p.pos, Kind: p.tok, Lit: p.lit}
+	p.next()
+	if p.tok != token.PERIOD {
+		return x
+	}
+	name := ast.QualifiedName{Parts: []ast.Ident{x}}
+	for p.expect(token.PERIOD) {
+		part := ast.Ident{LitPos: p.pos, Kind: p.tok, Lit: p.lit}
+		switch {
+		case p.tok == token.IDENT, p.tok == token.QUOTED_IDENT:
+		case p.tok == token.MUL && star:
+			// '*' ends the name
+			part.Kind, part.Lit = token.ASTA, "*"
+			p.next()
+			name.Parts = append(name.Parts, part)
+			return name
+		default:
+			p.errorExpected(p.pos, "identifier after '.'")
+			return ast.BadExpr{From: x.Pos(), To: p.pos}
+		}
+		p.next()
+		name.Parts = append(name.Parts, part)
+	}
+	return name
+}
+
+// isStar reports whether x is a qualified name ending in '*'.
+func isStar(x ast.Expr) bool {
+	name, ok := x.(ast.QualifiedName)
+	return ok && name.Parts[len(name.Parts)-1].Kind == token.ASTA
+}
+
+// parseCastExpr parses the postfix casts x::type following x.
+func (p *parser) parseCastExpr(x ast.Expr) ast.Expr {
+	for p.tok == token.CAST {
+		pos := p.pos
+		p.next()
+		x = ast.BinaryExpr{X: x, OpPos: pos, Op: token.CAST, Y: p.parsePrimaryExpr()}
+	}
+	return x
+}
+
+func (p *parser) parseCaseExpr() ast.Expr {
+	begin := p.pos
+	p.expect(token.CASE)
 
 	var key ast.Expr
 	switchKeyExists := false
@@ -353,18 +1059,11 @@ func (p *parser) parseCaseExpr() ast.CaseExpr {
 	}
 
 	var whens []*ast.WhenClause
-L:
-	for {
-		switch p.tok {
-		case token.WHEN:
-			w := p.parseWhenClause()
-			whens = append(whens, w)
-			// whens = append(whens, p.parseWhenClause())
-		case token.ELSE, token.END:
-			break L
-		default:
-			panic("paraser expect WHEN, ELSE, END token. but got " + p.tok.String())
-		}
+	for p.tok == token.WHEN {
+		whens = append(whens, p.parseWhenClause())
+	}
+	if len(whens) == 0 {
+		p.errorExpected(p.pos, "'WHEN'")
 	}
 
 	elseClus := ast.ElseClause{Exists: false}
@@ -376,8 +1075,8 @@ L:
 	}
 
 	endPos := p.pos
-	if !p.expect(token.END) {
-		panic("parser expects END token. but got " + p.tok.String())
+	if !p.mustExpect(token.END, "'END' to close CASE") {
+		return ast.BadExpr{From: begin, To: p.pos}
 	}
 
 	return ast.CaseExpr{Begin: begin, HasSwitchKey: switchKeyExists, SwitchKey: key, Whens: whens, Else: elseClus, EndPos: endPos}
@@ -385,16 +1084,12 @@ L:
 
 func (p *parser) parseWhenClause() *ast.WhenClause {
 	begin := p.pos
-	if !p.expect(token.WHEN) {
-		panic("parser expects WHEN token. but got " + p.tok.String())
-	}
+	p.expect(token.WHEN)
 
 	cond := p.parseExpr()
 
 	thenPos := p.pos
-	if !p.expect(token.THEN) {
-		panic("parser expects THEN token. but got " + p.tok.String())
-	}
+	p.mustExpect(token.THEN, "'THEN' after WHEN condition")
 
 	result := p.parseExpr()
 
@@ -403,63 +1098,106 @@ func (p *parser) parseWhenClause() *ast.WhenClause {
 
 func (p *parser) parsePrimaryExpr() ast.Expr {
 	switch p.tok {
-	case token.IDENT:
+	case token.IDENT, token.QUOTED_IDENT:
 		pos := p.pos
-		kind := p.tok
-		lit := p.lit
-		tbl := ""
-
-		p.next()
+		name := p.parseName(true)
+		if _, bad := name.(ast.BadExpr); bad {
+			return name
+		}
 
-		// Maybe table name
-		if p.tok == token.PERIOD {
-			p.next()
-			tbl = lit
-			lit = p.lit
-			if !p.expect(token.IDENT) {
-				panic("expect column name after table name. but got " + p.tok.String())
-			}
-			// Maybe function name
-		} else if p.tok == token.LPAREN {
+		// Maybe function name
+		if p.tok == token.LPAREN && !isStar(name) {
 			lparen := p.pos
-			var rparen token.Pos
-			var args []ast.Expr
 			p.next()
-		L:
-			for {
-				switch p.tok {
-				case token.RPAREN:
-					rparen = p.pos
-					p.next()
-					break L
-				case token.COMMA:
-					p.next()
-					continue
-				case token.EOF:
-					panic("while parsing CallExpr, got EOF.")
-				default:
-					args = append(args, p.parseExpr())
-				}
-
+			var args []ast.Expr
+			if p.tok != token.RPAREN {
+				args = p.parseExprList()
+			}
+			rparen := p.pos
+			if !p.mustExpect(token.RPAREN, "')' to close function call") {
+				return ast.BadExpr{From: pos, To: p.pos}
 			}
 
-			return ast.CallExpr{Begin: pos, FuncName: lit, Lparen: lparen, Args: args, Rparen: rparen}
+			return ast.CallExpr{Begin: pos, Func: name, Lparen: lparen, Args: args, Rparen: rparen}
 		}
 
-		return ast.Ident{TblName: tbl, LitPos: pos, Kind: kind, Lit: lit}
+		return name
 	case token.STRING, token.INT, token.REAL:
 		blit := ast.BasicLit{Begin: p.pos, Value: p.lit, Kind: p.tok}
 		p.next()
 		return blit
+	case token.NULL:
+		blit := ast.BasicLit{Begin: p.pos, Value: token.NULL.String(), Kind: token.NULL}
+		p.next()
+		return blit
+	case token.PARAM:
+		param := parseParam(p.pos, p.lit)
+		p.next()
+		return param
+	case token.LPAREN:
+		lparen := p.pos
+		p.next()
+		if isQueryStart(p.tok) {
+			return p.parseSubqueryBody(lparen)
+		}
+		x := p.parseExpr()
+		if p.tok == token.COMMA {
+			// row constructor
+			p.next()
+			list := append([]ast.Expr{x}, p.parseExprList()...)
+			rparen := p.pos
+			if !p.mustExpect(token.RPAREN, "')' to close list") {
+				return ast.BadExpr{From: lparen, To: p.pos}
+			}
+			return ast.ListExpr{Lparen: lparen, List: list, Rparen: rparen}
+		}
+		rparen := p.pos
+		if !p.mustExpect(token.RPAREN, "')'") {
+			return ast.BadExpr{From: lparen, To: p.pos}
+		}
+		return ast.ParenExpr{Lparen: lparen, X: x, Rparen: rparen}
 	}
 
-	// TODO mock return
-	var dummy ast.Expr
-	return dummy
+	pos := p.pos
+	p.errorExpected(pos, "expression")
+	p.skipBad()
+	return ast.BadExpr{From: pos, To: p.pos}
+}
+
+func (p *parser) next0() {
+	p.pos, p.tok, p.lit = p.scanner.Scan()
+}
+
+// consumeCommentGroup collects the current comment and the following
+// comments which start at most n lines after the end of the previous
+// one into a comment group.
+func (p *parser) consumeCommentGroup(n int) {
+	var list []*ast.Comment
+	endline := p.file.Line(p.pos)
+	for p.tok == token.COMMENT && p.file.Line(p.pos) <= endline+n {
+		comment := &ast.Comment{Slash: p.pos, Text: p.lit}
+		list = append(list, comment)
+		endline = p.file.Line(comment.End())
+		p.next0()
+	}
+	p.comments = append(p.comments, &ast.CommentGroup{List: list})
 }
 
+// next advances to the next non-comment token. Comments are collected
+// into p.comments. A comment on the same line as the previous token forms
+// a group of its own, so that it can be kept as a trailing comment.
 func (p *parser) next() {
-	p.pos, p.tok, p.lit = p.scanner.Scan()
+	prev := p.pos
+	p.next0()
+	if p.tok != token.COMMENT {
+		return
+	}
+	if prev != token.NoPos && p.file.Line(p.pos) == p.file.Line(prev) {
+		p.consumeCommentGroup(0)
+	}
+	for p.tok == token.COMMENT {
+		p.consumeCommentGroup(1)
+	}
 }
 
 func (p *parser) tokPrec() (token.Token, int) {
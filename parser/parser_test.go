@@ -3,9 +3,11 @@ package parser
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Neetless/sqlfmt/ast"
+	"github.com/Neetless/sqlfmt/scanner"
 	"github.com/Neetless/sqlfmt/token"
 )
 
@@ -18,9 +20,9 @@ func setTestData() []testData {
 	var testSet []testData
 	testSet = []testData{
 		testData{testSQL: `select c from t where t.v is null`,
-			expect: ast.SelectStmt{Begin: 1, Select: ast.SelectClause{Begin: 1, Cols: []*ast.Column{&ast.Column{Value: ast.Ident{TblName: "", LitPos: 8, Kind: token.IDENT, Lit: "c"}, Alias: "", EndPos: 9}}},
+			expect: ast.SelectStmt{Begin: 1, Select: ast.SelectClause{Begin: 1, Cols: []*ast.Column{&ast.Column{Value: ast.Ident{LitPos: 8, Kind: token.IDENT, Lit: "c"}, Alias: "", EndPos: 9}}},
 				From:    ast.FromClause{Begin: 10, Tables: []*ast.Table{&ast.Table{Value: ast.TableBasicLit{Begin: 15, Kind: token.IDENT, Name: "t"}, Alias: "", EndPos: 16}}},
-				Where:   ast.WhereClause{Begin: 17, CondExpr: ast.IsNullExpr{Value: ast.Ident{TblName: "t", LitPos: 23, Kind: token.IDENT, Lit: "v"}, IsPos: 27, NullPos: 30}, Exists: true},
+				Where:   ast.WhereClause{Begin: 17, CondExpr: ast.IsNullExpr{Value: ast.QualifiedName{Parts: []ast.Ident{{LitPos: 23, Kind: token.IDENT, Lit: "t"}, {LitPos: 25, Kind: token.IDENT, Lit: "v"}}}, IsPos: 27, NullPos: 30}, Exists: true},
 				Groupby: ast.GroupbyClause{Exists: false},
 				Orderby: ast.OrderbyClause{Exists: false},
 			},
@@ -38,13 +40,13 @@ when code < 2 then '2' end from tbl`,
 							Whens: []*ast.WhenClause{
 								&ast.WhenClause{
 									Begin:      13,
-									CondExpr:   ast.BinaryExpr{X: ast.Ident{TblName: "", LitPos: 18, Kind: token.IDENT, Lit: "code"}, OpPos: 22, Op: token.GTR, Y: ast.BasicLit{Begin: 25, Value: "1", Kind: token.INT}},
+									CondExpr:   ast.BinaryExpr{X: ast.Ident{LitPos: 18, Kind: token.IDENT, Lit: "code"}, OpPos: 22, Op: token.GTR, Y: ast.BasicLit{Begin: 25, Value: "1", Kind: token.INT}},
 									ThenPos:    27,
 									ResultExpr: ast.BasicLit{Begin: 32, Value: "'1'", Kind: token.STRING},
 								},
 								&ast.WhenClause{
 									Begin:      36,
-									CondExpr:   ast.BinaryExpr{X: ast.Ident{TblName: "", LitPos: 41, Kind: token.IDENT, Lit: "code"}, OpPos: 46, Op: token.LSS, Y: ast.BasicLit{Begin: 48, Value: "2", Kind: token.INT}},
+									CondExpr:   ast.BinaryExpr{X: ast.Ident{LitPos: 41, Kind: token.IDENT, Lit: "code"}, OpPos: 46, Op: token.LSS, Y: ast.BasicLit{Begin: 48, Value: "2", Kind: token.INT}},
 									ThenPos:    50,
 									ResultExpr: ast.BasicLit{Begin: 55, Value: "'2'", Kind: token.STRING},
 								},
@@ -69,7 +71,7 @@ when code < 2 then '2' end from tbl`,
 						Value: ast.CaseExpr{
 							Begin:        8,
 							HasSwitchKey: true,
-							SwitchKey:    ast.Ident{TblName: "", LitPos: 13, Kind: token.IDENT, Lit: "code"},
+							SwitchKey:    ast.Ident{LitPos: 13, Kind: token.IDENT, Lit: "code"},
 							Whens: []*ast.WhenClause{&ast.WhenClause{
 								Begin:      18,
 								CondExpr:   ast.BasicLit{Begin: 23, Value: "'0'", Kind: token.STRING},
@@ -96,7 +98,7 @@ when code < 2 then '2' end from tbl`,
 				From:    ast.FromClause{Begin: 14, Tables: []*ast.Table{&ast.Table{Value: ast.TableBasicLit{Begin: 19, Kind: token.IDENT, Name: "tbl"}, Alias: "", EndPos: 22}}},
 				Where:   ast.WhereClause{Exists: false},
 				Groupby: ast.GroupbyClause{Exists: false},
-				Orderby: ast.OrderbyClause{Begin: 29, ByPos: 35, Exists: true, Orders: []ast.Expr{ast.Ident{LitPos: 38, Lit: "score", Kind: token.IDENT}}},
+				Orderby: ast.OrderbyClause{Begin: 23, ByPos: 29, Exists: true, Orders: []ast.OrderItem{{X: ast.Ident{LitPos: 32, Lit: "score", Kind: token.IDENT}, EndPos: 37}}},
 			},
 		},
 		testData{testSQL: `select key from tbl GROUP BY key`,
@@ -114,10 +116,10 @@ when code < 2 then '2' end from tbl`,
 				Begin: 1,
 				Select: ast.SelectClause{Begin: 1, Cols: []*ast.Column{&ast.Column{
 					Value: ast.CallExpr{Begin: 8,
-						FuncName: "count",
-						Lparen:   13,
-						Args:     []ast.Expr{ast.BasicLit{Begin: 14, Value: "*", Kind: token.ASTA}},
-						Rparen:   15},
+						Func:   ast.Ident{LitPos: 8, Kind: token.IDENT, Lit: "count"},
+						Lparen: 13,
+						Args:   []ast.Expr{ast.BasicLit{Begin: 14, Value: "*", Kind: token.ASTA}},
+						Rparen: 15},
 					Alias:  "",
 					EndPos: 16}}},
 				From: ast.FromClause{Begin: 17, Tables: []*ast.Table{&ast.Table{
@@ -132,12 +134,12 @@ when code < 2 then '2' end from tbl`,
 					Begin: 3,
 					Cols: []*ast.Column{
 						&ast.Column{
-							Value:  ast.Ident{LitPos: 10, TblName: "", Kind: token.IDENT, Lit: "id"},
+							Value:  ast.Ident{LitPos: 10, Kind: token.IDENT, Lit: "id"},
 							Alias:  "",
 							EndPos: 12,
 						},
 						&ast.Column{
-							Value:  ast.Ident{LitPos: 14, TblName: "", Kind: token.IDENT, Lit: "username"},
+							Value:  ast.Ident{LitPos: 14, Kind: token.IDENT, Lit: "username"},
 							Alias:  "",
 							EndPos: 22,
 						},
@@ -162,30 +164,24 @@ when code < 2 then '2' end from tbl`,
 					Begin:  45,
 					CondExpr: ast.BinaryExpr{
 						X: ast.BinaryExpr{
-							X: ast.Ident{
-								LitPos:  51,
-								TblName: "id_mst",
-								Lit:     "id",
-								Kind:    token.IDENT,
-							},
+							X: ast.QualifiedName{Parts: []ast.Ident{
+								{LitPos: 51, Kind: token.IDENT, Lit: "id_mst"},
+								{LitPos: 58, Kind: token.IDENT, Lit: "id"},
+							}},
 							OpPos: 61,
 							Op:    token.EQL,
-							Y: ast.Ident{
-								LitPos:  63,
-								TblName: "user_mst",
-								Lit:     "id",
-								Kind:    token.IDENT,
-							},
+							Y: ast.QualifiedName{Parts: []ast.Ident{
+								{LitPos: 63, Kind: token.IDENT, Lit: "user_mst"},
+								{LitPos: 72, Kind: token.IDENT, Lit: "id"},
+							}},
 						},
 						OpPos: 75,
 						Op:    token.AND,
 						Y: ast.BinaryExpr{
-							X: ast.Ident{
-								LitPos:  79,
-								TblName: "user_mst",
-								Lit:     "dt",
-								Kind:    token.IDENT,
-							},
+							X: ast.QualifiedName{Parts: []ast.Ident{
+								{LitPos: 79, Kind: token.IDENT, Lit: "user_mst"},
+								{LitPos: 88, Kind: token.IDENT, Lit: "dt"},
+							}},
 							OpPos: 91,
 							Op:    token.GTR,
 							Y: ast.BasicLit{
@@ -207,12 +203,14 @@ when code < 2 then '2' end from tbl`,
 					Begin: 1,
 					Cols: []*ast.Column{
 						&ast.Column{
-							Value:  ast.Ident{LitPos: 8, TblName: "", Kind: token.IDENT, Lit: "col1"},
+							Value:  ast.Ident{LitPos: 8, Kind: token.IDENT, Lit: "col1"},
+							AsPos:  13,
 							Alias:  "id",
 							EndPos: 18,
 						},
 						&ast.Column{
-							Value:  ast.Ident{LitPos: 20, TblName: "", Kind: token.IDENT, Lit: "col2"},
+							Value:  ast.Ident{LitPos: 20, Kind: token.IDENT, Lit: "col2"},
+							AsPos:  25,
 							Alias:  "name",
 							EndPos: 32,
 						},
@@ -227,6 +225,7 @@ when code < 2 then '2' end from tbl`,
 								Kind:  token.IDENT,
 								Name:  "tbl1",
 							},
+							AsPos:  43,
 							Alias:  "user",
 							EndPos: 50,
 						},
@@ -236,6 +235,7 @@ when code < 2 then '2' end from tbl`,
 								Kind:  token.IDENT,
 								Name:  "tbl2",
 							},
+							AsPos:  57,
 							Alias:  "item",
 							EndPos: 64,
 						},
@@ -251,16 +251,19 @@ when code < 2 then '2' end from tbl`,
 func TestParseFileWithSrc(t *testing.T) {
 	// preparation
 	ts := setTestData()
-	fs := token.NewFileSet()
 
 	// test
 	for _, v := range ts {
-		stmt, err := ParseFile(fs, "test.sql", v.testSQL)
+		fs := token.NewFileSet()
+		f, err := ParseFile(fs, "test.sql", v.testSQL)
 		if err != nil {
 			t.Fatal(err)
 		}
 		t.Logf("given SQL: %s", v.testSQL)
-		nodeEqualTest(stmt, v.expect, t)
+		if len(f.Stmts) != 1 {
+			t.Fatalf("# of statements is incorrect. actual: %d, expect: 1.", len(f.Stmts))
+		}
+		nodeEqualTest(f.Stmts[0], v.expect, t)
 	}
 
 }
@@ -268,11 +271,15 @@ func TestParseFileWithSrc(t *testing.T) {
 // Test parsing select statement sql file.
 func TestParseFile(t *testing.T) {
 	fs := token.NewFileSet()
-	stmt, err := ParseFile(fs, "testdata/select_test.sql", nil)
+	f, err := ParseFile(fs, "testdata/select_test.sql", nil)
 
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if len(f.Stmts) != 1 {
+		t.Fatalf("# of statements is incorrect. actual: %d, expect: 1.", len(f.Stmts))
 	}
+	stmt := f.Stmts[0]
 
 	expectStmt := ast.SelectStmt{
 		Begin: 1,
@@ -309,6 +316,522 @@ func TestParseFile(t *testing.T) {
 	nodeEqualTest(stmt, expectStmt, t)
 }
 
+func TestParseExpr(t *testing.T) {
+	ident := func(name string) ast.Ident {
+		return ast.Ident{Kind: token.IDENT, Lit: name}
+	}
+	binary := func(x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+		return ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	a, b, c := ident("a"), ident("b"), ident("c")
+	one := ast.BasicLit{Value: "1", Kind: token.INT}
+
+	tests := []struct {
+		src    string
+		expect ast.Expr
+	}{
+		{"a >= b", binary(a, token.GEQ, b)},
+		{"a <= b", binary(a, token.LEQ, b)},
+		{"a <> b", binary(a, token.LSSGTR, b)},
+		{"a != b", binary(a, token.NEQ, b)},
+		{"a || b = c", binary(binary(a, token.CONCAT, b), token.EQL, c)},
+		{"a || b + c", binary(a, token.CONCAT, binary(b, token.ADD, c))},
+		{"a / b % c", binary(binary(a, token.QUO, b), token.REM, c)},
+		{"a + b / c", binary(a, token.ADD, binary(b, token.QUO, c))},
+		{"a::text || b", binary(binary(a, token.CAST, ident("text")), token.CONCAT, b)},
+		{"- a::int", ast.UnaryExpr{Op: token.SUB, X: binary(a, token.CAST, ident("int"))}},
+		{"any = all + some", binary(ident("any"), token.EQL, binary(ident("all"), token.ADD, ident("some")))},
+		{"a + b is null = c", binary(ast.IsNullExpr{Value: binary(a, token.ADD, b)}, token.EQL, c)},
+		{"-a is null", ast.IsNullExpr{Value: ast.UnaryExpr{Op: token.SUB, X: a}}},
+		{"not a is null", ast.UnaryExpr{Op: token.NOT, X: ast.IsNullExpr{Value: a}}},
+		{"a = b is null", ast.IsNullExpr{Value: binary(a, token.EQL, b)}},
+		{"not a is not null", ast.UnaryExpr{Op: token.NOT, X: ast.IsNullExpr{Value: a, Not: true}}},
+		{"null", ast.BasicLit{Value: "NULL", Kind: token.NULL}},
+		{"case when a then null else null end", ast.CaseExpr{
+			Whens: []*ast.WhenClause{{CondExpr: a, ResultExpr: ast.BasicLit{Value: "NULL", Kind: token.NULL}}},
+			Else:  ast.ElseClause{Exists: true, ResultExpr: ast.BasicLit{Value: "NULL", Kind: token.NULL}},
+		}},
+		{"a = b in (c)", binary(a, token.EQL, ast.InExpr{X: b, Set: ast.ListExpr{List: []ast.Expr{c}}})},
+		{"a-1 = -1", binary(binary(a, token.SUB, one), token.EQL, ast.UnaryExpr{Op: token.SUB, X: one})},
+		{"(a, b) = (c, c)", binary(ast.ListExpr{List: []ast.Expr{a, b}}, token.EQL, ast.ListExpr{List: []ast.Expr{c, c}})},
+		{"not a = b and c", binary(ast.UnaryExpr{Op: token.NOT, X: binary(a, token.EQL, b)}, token.AND, c)},
+		{"a not in (b, c)", ast.InExpr{X: a, Not: true, Set: ast.ListExpr{List: []ast.Expr{b, c}}}},
+		{"a + b in (c) = c", binary(ast.InExpr{X: binary(a, token.ADD, b), Set: ast.ListExpr{List: []ast.Expr{c}}}, token.EQL, c)},
+		{`"Order ID" = [b]`, binary(ast.Ident{Kind: token.QUOTED_IDENT, Lit: `"Order ID"`}, token.EQL, ast.Ident{Kind: token.QUOTED_IDENT, Lit: "[b]"})},
+		{"t.`c`", ast.QualifiedName{Parts: []ast.Ident{ident("t"), {Kind: token.QUOTED_IDENT, Lit: "`c`"}}}},
+		{`db."My Schema".tbl.col`, ast.QualifiedName{Parts: []ast.Ident{ident("db"), {Kind: token.QUOTED_IDENT, Lit: `"My Schema"`}, ident("tbl"), ident("col")}}},
+		{`"t".*`, ast.QualifiedName{Parts: []ast.Ident{{Kind: token.QUOTED_IDENT, Lit: `"t"`}, {Kind: token.ASTA, Lit: "*"}}}},
+		{"s.f(a)::pg_catalog.int4", binary(ast.CallExpr{Func: ast.QualifiedName{Parts: []ast.Ident{ident("s"), ident("f")}}, Args: []ast.Expr{a}}, token.CAST, ast.QualifiedName{Parts: []ast.Ident{ident("pg_catalog"), ident("int4")}})},
+	}
+	for _, test := range tests {
+		src := "select " + test.src + " from t"
+		f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		actual := f.Stmts[0].(ast.SelectStmt).Select.Cols[0].Value
+		if !ast.Equal(actual, test.expect) {
+			t.Errorf("%s is parsed incorrectly. actual: %#v", test.src, actual)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	src := `select a from t where a = ? and b = $2 or c = :name || @at; select :1 from t`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []ast.Param{
+		{Begin: 27, Style: ast.QuestionParam, Value: "?"},
+		{Begin: 37, Style: ast.DollarParam, Index: 2, Value: "$2"},
+		{Begin: 47, Style: ast.ColonParam, Name: "name", Value: ":name"},
+		{Begin: 56, Style: ast.AtParam, Name: "at", Value: "@at"},
+	}
+	if params := ast.Params(f.Stmts[0]); !reflect.DeepEqual(params, expect) {
+		t.Errorf("Params of the 1st statement are incorrect. actual: %+v, expect: %+v", params, expect)
+	}
+	expect = []ast.Param{{Begin: 68, Style: ast.ColonParam, Index: 1, Value: ":1"}}
+	if params := ast.Params(f.Stmts[1]); !reflect.DeepEqual(params, expect) {
+		t.Errorf("Params of the 2nd statement are incorrect. actual: %+v, expect: %+v", params, expect)
+	}
+
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(`select a from t order by a offset ? limit ?`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := ast.Params(f); len(params) != 2 || params[0].Begin != 35 || params[1].Begin != 43 {
+		t.Errorf("Params must be in source order. actual: %+v", params)
+	}
+}
+
+func TestParseJoin(t *testing.T) {
+	src := `select * from a join b on a.id = b.id left join c as x using (id), d`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := f.Stmts[0].(ast.SelectStmt).From.Tables
+	if len(tables) != 2 {
+		t.Fatalf("# of tables is incorrect. actual: %d, expect: 2.", len(tables))
+	}
+
+	// ((a JOIN b ON ...) LEFT JOIN c AS x USING (id))
+	outer, ok := tables[0].Value.(ast.JoinExpr)
+	if !ok {
+		t.Fatalf("1st table is %T, expect ast.JoinExpr.", tables[0].Value)
+	}
+	if outer.Kind != token.LEFT || outer.Right.Alias != "x" || len(outer.Using) != 1 || outer.Using[0].Lit != "id" {
+		t.Errorf("outer join is incorrect: %+v", outer)
+	}
+	inner, ok := outer.Left.Value.(ast.JoinExpr)
+	if !ok {
+		t.Fatalf("left of the outer join is %T, expect ast.JoinExpr.", outer.Left.Value)
+	}
+	if inner.Kind != token.JOIN || inner.Cond == nil || inner.Left.Value.(ast.TableBasicLit).Name != "a" {
+		t.Errorf("inner join is incorrect: %+v", inner)
+	}
+	if tables[0].Pos() != 15 || tables[0].End() != 66 {
+		t.Errorf("join position is incorrect. actual: %d-%d, expect: 15-66.", tables[0].Pos(), tables[0].End())
+	}
+
+	// LEFT, RIGHT and FULL are join keywords only before JOIN or OUTER
+	src = `select left(name, 3), full from t right /* r */ outer join u on t.id = u.id, v as left where left = 1`
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	if call, ok := stmt.Select.Cols[0].Value.(ast.CallExpr); !ok || call.Func.(ast.Ident).Lit != "left" {
+		t.Errorf("left() must be a function call: %+v", stmt.Select.Cols[0].Value)
+	}
+	if join, ok := stmt.From.Tables[0].Value.(ast.JoinExpr); !ok || join.Kind != token.RIGHT || !join.Outer || join.Left.Alias != "" {
+		t.Errorf("right outer join is incorrect: %+v", stmt.From.Tables[0])
+	}
+	if stmt.From.Tables[1].Alias != "left" {
+		t.Errorf("left not followed by JOIN must be an alias: %+v", stmt.From.Tables[1])
+	}
+
+	// a contextual keyword is an alias only after AS
+	if _, err := ParseFile(token.NewFileSet(), "test.sql", []byte("select a from t left")); err == nil {
+		t.Errorf("left without AS must not be an alias")
+	}
+}
+
+func TestParseParenExpr(t *testing.T) {
+	src := "select (a + b) * c from t"
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := f.Stmts[0].(ast.SelectStmt).Select.Cols[0].Value
+	mul, ok := x.(ast.BinaryExpr)
+	if !ok || mul.Op != token.MUL {
+		t.Fatalf("%s is parsed incorrectly. actual: %#v", src, x)
+	}
+	paren, ok := mul.X.(ast.ParenExpr)
+	if !ok {
+		t.Fatalf("left operand is %T, expect ast.ParenExpr.", mul.X)
+	}
+	if add, ok := paren.X.(ast.BinaryExpr); !ok || add.Op != token.ADD {
+		t.Errorf("parenthesized expression is incorrect. actual: %#v", paren.X)
+	}
+	if paren.Pos() != 8 || paren.End() != 15 {
+		t.Errorf("ParenExpr position is incorrect. actual: %d-%d, expect: 8-15.", paren.Pos(), paren.End())
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	src := `with recursive a (x, y) as (select 1 from t), b as materialized (select 2 from a), c as not materialized (select 3 from b) select * from c`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	with := stmt.With
+	if !with.Exists || !with.Recursive || len(with.CTEs) != 3 {
+		t.Fatalf("WITH clause is incorrect: %+v", with)
+	}
+	if stmt.Pos() != 1 || with.Pos() != 1 || with.End() != token.Pos(len("with recursive a (x, y) as (select 1 from t), b as materialized (select 2 from a), c as not materialized (select 3 from b)")+1) {
+		t.Errorf("WITH clause position is incorrect. actual: %d-%d", with.Pos(), with.End())
+	}
+	a, b, c := with.CTEs[0], with.CTEs[1], with.CTEs[2]
+	if a.Name != "a" || len(a.Cols) != 2 || a.Cols[1].Lit != "y" || a.Materialized || a.NotMaterialized {
+		t.Errorf("1st CTE is incorrect: %+v", a)
+	}
+	if b.Name != "b" || b.Cols != nil || !b.Materialized || b.NotMaterialized {
+		t.Errorf("2nd CTE is incorrect: %+v", b)
+	}
+	if c.Name != "c" || c.Materialized || !c.NotMaterialized {
+		t.Errorf("3rd CTE is incorrect: %+v", c)
+	}
+
+	src = `with recursive as (select materialized from t) select recursive.materialized from recursive`
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if with := f.Stmts[0].(ast.SelectStmt).With; with.Recursive || with.CTEs[0].Name != "recursive" {
+		t.Errorf("recursive not followed by a name must be a CTE name: %+v", with)
+	}
+}
+
+func TestParseSetOp(t *testing.T) {
+	src := `with w as (select 1 from t) select a from t union select b from u intersect all select c from v except select d from w order by 1`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ((t UNION (u INTERSECT ALL v)) EXCEPT w) ORDER BY 1
+	except, ok := f.Stmts[0].(ast.SetOpStmt)
+	if !ok {
+		t.Fatalf("statement is %T, expect ast.SetOpStmt.", f.Stmts[0])
+	}
+	if except.Op != token.EXCEPT || !except.With.Exists || !except.Orderby.Exists {
+		t.Errorf("EXCEPT is incorrect: %+v", except)
+	}
+	if except.Pos() != 1 || except.End() != token.Pos(len(src)+1) {
+		t.Errorf("compound query position is incorrect. actual: %d-%d", except.Pos(), except.End())
+	}
+	if y, ok := except.Y.(ast.SelectStmt); !ok || y.Orderby.Exists {
+		t.Errorf("ORDER BY must apply to the whole compound query: %+v", except.Y)
+	}
+	union, ok := except.X.(ast.SetOpStmt)
+	if !ok || union.Op != token.UNION || union.All || union.Distinct {
+		t.Fatalf("UNION is incorrect: %+v", except.X)
+	}
+	intersect, ok := union.Y.(ast.SetOpStmt)
+	if !ok || intersect.Op != token.INTERSECT || !intersect.All {
+		t.Errorf("INTERSECT must bind tighter than UNION: %+v", union.Y)
+	}
+}
+
+func TestParseParenSetOp(t *testing.T) {
+	src := `(select a from t union select a from u) intersect (select a from v order by a limit 1) order by a`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intersect, ok := f.Stmts[0].(ast.SetOpStmt)
+	if !ok || intersect.Op != token.INTERSECT || !intersect.Orderby.Exists {
+		t.Fatalf("INTERSECT is incorrect: %+v", f.Stmts[0])
+	}
+	x, ok := intersect.X.(ast.ParenStmt)
+	if !ok || x.Pos() != 1 || x.End() != 40 {
+		t.Fatalf("left operand is incorrect: %+v", intersect.X)
+	}
+	if union, ok := x.Query.(ast.SetOpStmt); !ok || union.Op != token.UNION {
+		t.Errorf("UNION must be kept in parentheses: %+v", x.Query)
+	}
+	y, ok := intersect.Y.(ast.ParenStmt)
+	if !ok {
+		t.Fatalf("right operand is %T, expect ast.ParenStmt.", intersect.Y)
+	}
+	if q, ok := y.Query.(ast.SelectStmt); !ok || !q.Orderby.Exists || !q.Limit.Exists {
+		t.Errorf("ORDER BY and LIMIT must apply to the parenthesized query: %+v", y.Query)
+	}
+
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(`(select a from t) limit 1`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt, ok := f.Stmts[0].(ast.ParenStmt); !ok || !stmt.Limit.Exists {
+		t.Errorf("LIMIT must apply to the parenthesized statement: %+v", f.Stmts[0])
+	}
+}
+
+func TestParseSelectModifiers(t *testing.T) {
+	src := `select distinct on (a, b) a, b from t group by a, b having count(a) > 1 order by a offset 5 rows fetch first 10 rows only`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	if !stmt.Select.Distinct || len(stmt.Select.DistinctOn) != 2 {
+		t.Errorf("DISTINCT ON is incorrect: %+v", stmt.Select)
+	}
+	if !stmt.Having.Exists || stmt.Having.Pos() != 53 {
+		t.Errorf("HAVING is incorrect: %+v", stmt.Having)
+	}
+	if stmt.Limit.Exists || stmt.Offset.Rows != "ROWS" || stmt.Fetch.First != "FIRST" || stmt.Fetch.Rows != "ROWS" || stmt.Fetch.WithTies {
+		t.Errorf("OFFSET or FETCH is incorrect: %+v, %+v", stmt.Offset, stmt.Fetch)
+	}
+	if stmt.End() != token.Pos(len(src)+1) {
+		t.Errorf("statement end is incorrect. actual: %d, expect: %d", stmt.End(), len(src)+1)
+	}
+
+	src = `select top 5 a from t union select b from u limit 3 offset 2; select a from t fetch next row with ties`
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	union := f.Stmts[0].(ast.SetOpStmt)
+	if x := union.X.(ast.SelectStmt); x.Select.Top == nil || x.Select.Distinct {
+		t.Errorf("TOP is incorrect: %+v", x.Select)
+	}
+	if !union.Limit.Exists || !union.Offset.Exists || union.End() != 61 {
+		t.Errorf("LIMIT and OFFSET must apply to the whole compound query: %+v", union)
+	}
+	if fetch := f.Stmts[1].(ast.SelectStmt).Fetch; fetch.Count != nil || !fetch.WithTies || fetch.End() != token.Pos(len(src)+1) {
+		t.Errorf("FETCH without count is incorrect: %+v", fetch)
+	}
+
+	src = `select top, "offset" as offset, limit from t limit 2 offset 1 fetch first 1 row only`
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt := f.Stmts[0].(ast.SelectStmt); stmt.Select.Top != nil || len(stmt.Select.Cols) != 3 || !stmt.Limit.Exists || !stmt.Offset.Exists || !stmt.Fetch.Exists {
+		t.Errorf("TOP, LIMIT and OFFSET must name columns outside of their clauses: %+v", stmt)
+	}
+
+	_, err = ParseFile(token.NewFileSet(), "test.sql", []byte("select a from t where a = 1 x"))
+	if expect := "test.sql:1:29: expected 'GROUP', 'HAVING', 'ORDER', 'LIMIT', 'OFFSET', 'FETCH' or ';', found x"; err == nil || err.Error() != expect {
+		t.Errorf("unexpected token after WHERE must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestParseOrderItem(t *testing.T) {
+	src := `select a from t order by a desc nulls last, b collate utf8mb4_bin asc, c nulls first, d`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := f.Stmts[0].(ast.SelectStmt).Orderby.Orders
+	expect := []ast.OrderItem{
+		{X: ast.Ident{LitPos: 26, Kind: token.IDENT, Lit: "a"}, DirPos: 28, Dir: token.DESC, NullsPos: 33, Nulls: "LAST", EndPos: 43},
+		{X: ast.Ident{LitPos: 45, Kind: token.IDENT, Lit: "b"}, CollatePos: 47, Collate: "utf8mb4_bin", DirPos: 67, Dir: token.ASC, EndPos: 70},
+		{X: ast.Ident{LitPos: 72, Kind: token.IDENT, Lit: "c"}, NullsPos: 74, Nulls: "FIRST", EndPos: 85},
+		{X: ast.Ident{LitPos: 87, Kind: token.IDENT, Lit: "d"}, EndPos: 88},
+	}
+	if !reflect.DeepEqual(orders, expect) {
+		t.Errorf("ORDER BY items are incorrect.\nactual: %+v\nexpect: %+v", orders, expect)
+	}
+
+	_, err = ParseFile(token.NewFileSet(), "test.sql", []byte("select a from t order by a nulls"))
+	if expect := "test.sql:1:33: expected 'FIRST' or 'LAST' after NULLS, found 'EOF'"; err == nil || err.Error() != expect {
+		t.Errorf("NULLS without FIRST or LAST must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestParseImplicitAlias(t *testing.T) {
+	src := `select u.id uid, count(*) "total" from users u join (select 1 from t) d on u.id = d.id`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	cols := stmt.Select.Cols
+	if cols[0].Alias != "uid" || cols[0].AsPos != token.NoPos || cols[0].End() != 16 {
+		t.Errorf("implicit column alias is incorrect: %+v", cols[0])
+	}
+	if cols[1].Alias != `"total"` || cols[1].AsPos != token.NoPos {
+		t.Errorf("quoted implicit column alias is incorrect: %+v", cols[1])
+	}
+	join := stmt.From.Tables[0].Value.(ast.JoinExpr)
+	if join.Left.Alias != "u" || join.Left.End() != 47 || join.Right.Alias != "d" {
+		t.Errorf("implicit table alias is incorrect: %+v, %+v", join.Left, join.Right)
+	}
+
+	f, err = ParseFile(token.NewFileSet(), "test.sql", []byte(`select a from t for update`))
+	if err == nil || f.Stmts[0].(ast.BadStmt).From != 1 {
+		t.Errorf("reserved word must not be an implicit alias. actual: %v", err)
+	}
+}
+
+func TestParseQualifiedName(t *testing.T) {
+	src := `select u.*, u.id from db."Sales".users u`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	if x := stmt.Select.Cols[1].Value; x.Pos() != 13 || x.End() != 17 {
+		t.Errorf("qualified column position is incorrect. actual: %d-%d, expect: 13-17.", x.Pos(), x.End())
+	}
+	tbl := stmt.From.Tables[0]
+	name, ok := tbl.Value.(ast.QualifiedName)
+	if !ok || len(name.Parts) != 3 || name.Parts[1].Kind != token.QUOTED_IDENT || tbl.Alias != "u" {
+		t.Fatalf("qualified table name is incorrect: %+v", tbl)
+	}
+	if name.Pos() != 23 || name.End() != 39 {
+		t.Errorf("qualified table position is incorrect. actual: %d-%d, expect: 23-39.", name.Pos(), name.End())
+	}
+
+	_, err = ParseFile(token.NewFileSet(), "test.sql", []byte("select a from s.*"))
+	if expect := "test.sql:1:17: expected identifier after '.', found '*'"; err == nil || err.Error() != expect {
+		t.Errorf("'*' in table name must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestParseSubquery(t *testing.T) {
+	src := `select (select b from u) from (select a from v where a in (select a from w)) as d where exists (select 1 from x) or a = any (select a from y)`
+	f, err := ParseFile(token.NewFileSet(), "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subqueries []ast.SubqueryExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if s, ok := n.(ast.SubqueryExpr); ok {
+			subqueries = append(subqueries, s)
+		}
+		return true
+	})
+	if len(subqueries) != 5 {
+		t.Fatalf("# of subqueries is incorrect. actual: %d, expect: 5.", len(subqueries))
+	}
+	if s := subqueries[0]; s.Pos() != 8 || s.End() != 25 {
+		t.Errorf("subquery position is incorrect. actual: %d-%d, expect: 8-25.", s.Pos(), s.End())
+	}
+
+	_, err = ParseFile(token.NewFileSet(), "test.sql", []byte("select a from (select a from v) where a = 1"))
+	if expect := "test.sql:1:33: expected alias name for derived table, found 'WHERE'"; err == nil || err.Error() != expect {
+		t.Errorf("derived table without alias must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestParseFileMultiStmts(t *testing.T) {
+	fs := token.NewFileSet()
+	src := `select a from t1;
+;
+select b from t2 where b = 1;
+
+select c from t3 group by c order by c`
+	f, err := ParseFile(fs, "test.sql", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []ast.Stmt{
+		ast.SelectStmt{
+			Begin:  1,
+			Select: ast.SelectClause{Begin: 1, Cols: []*ast.Column{&ast.Column{Value: ast.Ident{LitPos: 8, Kind: token.IDENT, Lit: "a"}, EndPos: 9}}},
+			From:   ast.FromClause{Begin: 10, Tables: []*ast.Table{&ast.Table{Value: ast.TableBasicLit{Begin: 15, Kind: token.IDENT, Name: "t1"}, EndPos: 17}}},
+			Where:  ast.WhereClause{Exists: false},
+		},
+		ast.SelectStmt{
+			Begin:  21,
+			Select: ast.SelectClause{Begin: 21, Cols: []*ast.Column{&ast.Column{Value: ast.Ident{LitPos: 28, Kind: token.IDENT, Lit: "b"}, EndPos: 29}}},
+			From:   ast.FromClause{Begin: 30, Tables: []*ast.Table{&ast.Table{Value: ast.TableBasicLit{Begin: 35, Kind: token.IDENT, Name: "t2"}, EndPos: 37}}},
+			Where: ast.WhereClause{Begin: 38, Exists: true, CondExpr: ast.BinaryExpr{
+				X:     ast.Ident{LitPos: 44, Kind: token.IDENT, Lit: "b"},
+				OpPos: 46,
+				Op:    token.EQL,
+				Y:     ast.BasicLit{Begin: 48, Value: "1", Kind: token.INT},
+			}},
+		},
+		ast.SelectStmt{
+			Begin:   52,
+			Select:  ast.SelectClause{Begin: 52, Cols: []*ast.Column{&ast.Column{Value: ast.Ident{LitPos: 59, Kind: token.IDENT, Lit: "c"}, EndPos: 60}}},
+			From:    ast.FromClause{Begin: 61, Tables: []*ast.Table{&ast.Table{Value: ast.TableBasicLit{Begin: 66, Kind: token.IDENT, Name: "t3"}, EndPos: 68}}},
+			Where:   ast.WhereClause{Exists: false},
+			Groupby: ast.GroupbyClause{Begin: 69, ByPos: 75, Exists: true, Groups: []ast.Expr{ast.Ident{LitPos: 78, Kind: token.IDENT, Lit: "c"}}},
+			Orderby: ast.OrderbyClause{Begin: 80, ByPos: 86, Exists: true, Orders: []ast.OrderItem{{X: ast.Ident{LitPos: 89, Kind: token.IDENT, Lit: "c"}, EndPos: 90}}},
+		},
+	}
+	if len(f.Stmts) != len(expect) {
+		t.Fatalf("# of statements is incorrect. actual: %d, expect: %d.", len(f.Stmts), len(expect))
+	}
+	for ix, stmt := range f.Stmts {
+		nodeEqualTest(stmt, expect[ix], t)
+	}
+	if semis := []token.Pos{19, 49, token.NoPos}; !reflect.DeepEqual(f.Semicolons, semis) {
+		t.Errorf("semicolon positions are incorrect. actual: %v, expect: %v.", f.Semicolons, semis)
+	}
+}
+
+func TestParseFileComments(t *testing.T) {
+	fs := token.NewFileSet()
+	src := `-- lead 1
+-- lead 2
+select a, -- line
+  b
+/* from */ from t;
+-- tail`
+	f, err := ParseFile(fs, "test.sql", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := [][]string{
+		[]string{"-- lead 1", "-- lead 2"},
+		[]string{"-- line"},
+		[]string{"/* from */"},
+		[]string{"-- tail"},
+	}
+	if len(f.Comments) != len(expect) {
+		t.Fatalf("# of comment groups is incorrect. actual: %d, expect: %d.", len(f.Comments), len(expect))
+	}
+	for ix, g := range f.Comments {
+		var actual []string
+		for _, c := range g.List {
+			actual = append(actual, c.Text)
+		}
+		if !reflect.DeepEqual(actual, expect[ix]) {
+			t.Errorf("%dth comment group is incorrect. actual: %q, expect: %q.", ix, actual, expect[ix])
+		}
+	}
+
+	stmt := f.Stmts[0].(ast.SelectStmt)
+	cmap := ast.NewCommentMap(fs, f, f.Comments)
+	assocs := []struct {
+		node   ast.Node
+		groups []*ast.CommentGroup
+	}{
+		{stmt, []*ast.CommentGroup{f.Comments[0], f.Comments[3]}},
+		{stmt.Select.Cols[0], []*ast.CommentGroup{f.Comments[1]}},
+		{stmt.From, []*ast.CommentGroup{f.Comments[2]}},
+	}
+	for _, a := range assocs {
+		groups := cmap.Comments(a.node)
+		if !reflect.DeepEqual(groups, a.groups) {
+			t.Errorf("comments of %T at %d are incorrect. actual: %v, expect: %v.", a.node, a.node.Pos(), groups, a.groups)
+		}
+	}
+}
+
 func nodeEqualTest(actual, expect ast.Node, t *testing.T) {
 	t.Log("Node pos/end check.")
 	posEqualTest(actual, expect, t)
@@ -501,13 +1024,6 @@ func exprEqualTest(actual, expect ast.Expr, t *testing.T) {
 		if !ok {
 			t.Fatal("actual type is not ast.Ident. " + typemsg)
 		}
-		if actualExpr.TblName != expectExpr.TblName {
-			t.Fatalf(
-				"Ident table name is incorrect. actual: %s, expected: %s.",
-				actualExpr.TblName,
-				expectExpr.TblName,
-			)
-		}
 		if actualExpr.Kind != expectExpr.Kind {
 			t.Fatalf(
 				"Ident kind is incorrect. actual: %s, expected: %s.",
@@ -522,6 +1038,17 @@ func exprEqualTest(actual, expect ast.Expr, t *testing.T) {
 				expectExpr.Lit,
 			)
 		}
+	case ast.QualifiedName:
+		actualExpr, ok := actual.(ast.QualifiedName)
+		if !ok {
+			t.Fatal("actual type is not ast.QualifiedName. " + typemsg)
+		}
+		if len(actualExpr.Parts) != len(expectExpr.Parts) {
+			t.Fatalf("QualifiedName parts size is incorrect. actual: %d, expect: %d.", len(actualExpr.Parts), len(expectExpr.Parts))
+		}
+		for ix, part := range actualExpr.Parts {
+			exprEqualTest(part, expectExpr.Parts[ix], t)
+		}
 	case ast.BinaryExpr:
 		actualExpr, ok := actual.(ast.BinaryExpr)
 		if !ok {
@@ -537,9 +1064,7 @@ func exprEqualTest(actual, expect ast.Expr, t *testing.T) {
 		if !ok {
 			t.Fatal("actual type is not ast.BinaryExpr. " + typemsg)
 		}
-		if actualExpr.FuncName != expectExpr.FuncName {
-			t.Fatalf("CallExpr FuncName is incorrect. actual: %s, expect: %s.", actualExpr.FuncName, expectExpr.FuncName)
-		}
+		exprEqualTest(actualExpr.Func, expectExpr.Func, t)
 		if len(actualExpr.Args) != len(expectExpr.Args) {
 			t.Fatalf("CallExpr Args size is incorrect. actual: %d, expect: %d.", len(actualExpr.Args), len(expectExpr.Args))
 		}
@@ -611,3 +1136,64 @@ func posEqualTest(actual, expect ast.Node, t *testing.T) {
 		)
 	}
 }
+
+func TestParseFileErrors(t *testing.T) {
+	src := `select a b c from t;
+select a from t where;
+select from t;
+select a from t group x;
+update t;
+select a from t`
+	fs := token.NewFileSet()
+	f, err := ParseFile(fs, "test.sql", src)
+	if err == nil {
+		t.Fatal("ParseFile returned no error.")
+	}
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("error type is not scanner.ErrorList. actual: %T", err)
+	}
+
+	expect := []string{
+		"test.sql:1:12: expected 'FROM', found c",
+		"test.sql:2:22: expected expression, found ';'",
+		"test.sql:3:8: expected expression, found 'FROM'",
+		"test.sql:4:23: expected 'BY' after GROUP, found x",
+		"test.sql:5:1: expected statement, found update",
+	}
+	var actual []string
+	for _, e := range list {
+		actual = append(actual, e.Error())
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("errors are incorrect.\nactual: %q\nexpect: %q", actual, expect)
+	}
+
+	if len(f.Stmts) != 6 {
+		t.Fatalf("# of statements is incorrect. actual: %d, expect: 6.", len(f.Stmts))
+	}
+	for ix, stmt := range f.Stmts[:5] {
+		if _, ok := stmt.(ast.BadStmt); !ok {
+			t.Errorf("%dth statement is not ast.BadStmt. actual: %T", ix, stmt)
+		}
+	}
+	if _, ok := f.Stmts[5].(ast.SelectStmt); !ok {
+		t.Errorf("last statement is not ast.SelectStmt. actual: %T", f.Stmts[5])
+	}
+}
+
+func TestParseFileBailout(t *testing.T) {
+	src := "select a from t;\nselect b from u;\n" + strings.Repeat("select from t;\n", maxErrors+1)
+	f, err := ParseFile(token.NewFileSet(), "test.sql", src)
+	if list, ok := err.(scanner.ErrorList); !ok || len(list) != maxErrors {
+		t.Fatalf("parsing must stop after %d errors. actual: %v", maxErrors, err)
+	}
+	if len(f.Stmts) < 2 {
+		t.Fatalf("statements before the bailout are lost. actual: %d statements", len(f.Stmts))
+	}
+	for ix, stmt := range f.Stmts[:2] {
+		if _, ok := stmt.(ast.SelectStmt); !ok {
+			t.Errorf("%dth statement is not ast.SelectStmt. actual: %T", ix, stmt)
+		}
+	}
+}
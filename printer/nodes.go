@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/Neetless/sqlfmt/ast"
+	"github.com/Neetless/sqlfmt/scanner"
+	"github.com/Neetless/sqlfmt/token"
+)
+
+// cmpOperandPrec is the precedence at which the operands of comparisons,
+// IN and IS NULL are printed. An operand which is one of them itself is
+// parenthesized, since databases disagree on how they group.
+const cmpOperandPrec = token.InPrec + 1
+
+// expr prints the expression x.
+func (p *printer) expr(x ast.Expr) {
+	p.expr1(x, token.LowestPrec)
+}
+
+// expr1 prints the expression x. x is enclosed in parentheses if its
+// precedence is lower than prec1, so that the printed expression is
+// parsed into the same tree.
+func (p *printer) expr1(x ast.Expr, prec1 int) {
+	p.exprComments(x.Pos())
+	switch n := x.(type) {
+	case ast.BadExpr:
+		// there is no text to print for a bad expression
+		if p.err == nil {
+			var pos token.Position
+			if p.fset != nil {
+				pos = p.fset.Position(n.Pos())
+			}
+			p.err = &scanner.Error{Pos: pos, Msg: "cannot print a bad expression"}
+		}
+
+	case ast.BasicLit:
+		p.print(n.Value)
+
+	case ast.Param:
+		p.print(n.Value)
+
+	case ast.Ident:
+		p.print(n.Lit)
+
+	case ast.QualifiedName:
+		p.qualifiedName(n)
+
+	case ast.CallExpr:
+		p.expr(n.Func)
+		p.print(token.LPAREN.String())
+		p.exprList(n.Args)
+		p.trailingComments(n.Rparen, n.Rparen)
+		p.print(token.RPAREN.String())
+
+	case ast.UnaryExpr:
+		prec := token.UnaryPrec
+		if n.Op == token.NOT {
+			prec = token.NotPrec
+		}
+		if prec < prec1 {
+			p.print(token.LPAREN.String())
+			defer p.print(token.RPAREN.String())
+		}
+		p.print(n.Op.String())
+		if n.Op.IsKeyword() || n.Op == token.SUB && p.startsWithMinus(n.X) {
+			// avoid "NOTx" and "--x", which would start a comment
+			p.print(" ")
+		}
+		p.expr1(n.X, prec)
+
+	case ast.BinaryExpr:
+		prec := n.Op.Precedence()
+		if prec < prec1 {
+			p.print(token.LPAREN.String())
+			defer p.print(token.RPAREN.String())
+		}
+		xprec, yprec := prec, prec+1 // binary operators are left associative
+		if prec == token.EQL.Precedence() {
+			xprec, yprec = cmpOperandPrec, cmpOperandPrec
+		}
+		p.expr1(n.X, xprec)
+		p.exprComments(n.OpPos)
+		if n.Op == token.CAST {
+			p.print(n.Op.String())
+		} else {
+			p.blank()
+			p.print(n.Op.String() + " ")
+		}
+		p.expr1(n.Y, yprec)
+
+	case ast.IsNullExpr:
+		if token.IsNullPrec < prec1 {
+			p.print(token.LPAREN.String())
+			defer p.print(token.RPAREN.String())
+		}
+		p.expr1(n.Value, cmpOperandPrec)
+		p.exprComments(n.IsPos)
+		p.blank()
+		p.print(token.IS.String())
+		if n.Not {
+			p.print(" " + token.NOT.String())
+		}
+		p.exprComments(n.NullPos)
+		p.blank()
+		p.print(token.NULL.String())
+
+	case ast.CaseExpr:
+		p.caseExpr(n)
+
+	case ast.ParenExpr:
+		if p.RemoveParens {
+			// parenthesize only where the precedence requires it
+			p.expr1(n.X, prec1)
+			break
+		}
+		p.print(token.LPAREN.String())
+		p.expr(n.X)
+		p.trailingComments(n.Rparen, n.Rparen)
+		p.print(token.RPAREN.String())
+
+	case ast.SubqueryExpr:
+		p.subquery(n)
+
+	case ast.ListExpr:
+		p.print(token.LPAREN.String())
+		p.exprList(n.List)
+		p.trailingComments(n.Rparen, n.Rparen)
+		p.print(token.RPAREN.String())
+
+	case ast.InExpr:
+		if token.InPrec < prec1 {
+			p.print(token.LPAREN.String())
+			defer p.print(token.RPAREN.String())
+		}
+		p.expr1(n.X, cmpOperandPrec)
+		p.exprComments(n.InPos)
+		p.blank()
+		if n.Not {
+			p.print(token.NOT.String() + " ")
+		}
+		p.print(token.IN.String() + " ")
+		p.expr(n.Set)
+
+	default:
+		panic(fmt.Sprintf("printer: unsupported expression type %T", x))
+	}
+}
+
+// qualifiedName prints the parts of a qualified name separated by
+// periods.
+func (p *printer) qualifiedName(n ast.QualifiedName) {
+	for i, x := range n.Parts {
+		if i > 0 {
+			p.print(token.PERIOD.String())
+		}
+		p.print(x.Lit)
+	}
+}
+
+// exprList prints a comma separated list of expressions.
+func (p *printer) exprList(list []ast.Expr) {
+	for i, x := range list {
+		if i > 0 {
+			p.commaComments(list[i-1].End(), x.Pos())
+			p.print(token.COMMA.String() + " ")
+		}
+		p.expr(x)
+	}
+}
+
+// caseExpr prints a case expression with each WHEN and ELSE clause on
+// its own line, indented one level deeper than CASE and END. Comments
+// stay after the keyword or expression which they followed.
+func (p *printer) caseExpr(n ast.CaseExpr) {
+	p.print(token.CASE.String())
+	if n.HasSwitchKey {
+		p.print(" ")
+		p.expr(n.SwitchKey)
+	}
+	p.indent++
+	for _, w := range n.Whens {
+		p.exprComments(w.Begin)
+		p.endLine()
+		p.print(token.WHEN.String() + " ")
+		p.expr(w.CondExpr)
+		p.exprComments(w.ThenPos)
+		p.blank()
+		p.print(token.THEN.String() + " ")
+		p.expr(w.ResultExpr)
+	}
+	if n.Else.Exists {
+		p.exprComments(n.Else.Begin)
+		p.endLine()
+		p.print(token.ELSE.String() + " ")
+		p.expr(n.Else.ResultExpr)
+	}
+	p.exprComments(n.EndPos)
+	p.indent--
+	p.endLine()
+	p.reindent()
+	p.print(token.END.String())
+}
+
+// startsWithMinus reports whether the printed form of x starts with '-'.
+func (p *printer) startsWithMinus(x ast.Expr) bool {
+	switch n := x.(type) {
+	case ast.ParenExpr:
+		return p.RemoveParens && p.startsWithMinus(n.X)
+	case ast.UnaryExpr:
+		return n.Op == token.SUB
+	}
+	return false
+}
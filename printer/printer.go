@@ -1,12 +1,15 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/Neetless/sqlfmt/ast"
+	"github.com/Neetless/sqlfmt/parser"
 	"github.com/Neetless/sqlfmt/token"
 )
 
@@ -18,16 +21,35 @@ type printer struct {
 	output []byte
 
 	outputPos token.Position
+
+	comments    []*ast.CommentGroup // comments of the printed file
+	cindex      int                 // index of the next comment group to print
+	lineComment bool                // the current line ends with a -- comment
+
+	err error // the first error found while printing
+}
+
+// infinity is a position after any position of a source.
+const infinity = token.Pos(1<<31 - 1)
+
+// A CommentedNode bundles an AST node and the comments which belong to
+// it, so that a node other than *ast.File can be printed with its
+// comments, e.g. the comments an ast.CommentMap filtered for the node
+// associates with it.
+type CommentedNode struct {
+	Node     interface{} // *ast.File, ast.Stmt or ast.Expr
+	Comments []*ast.CommentGroup
 }
 
-// Fprint "pretty-prints" an AST node to Fprint.
+// Fprint "pretty-prints" an AST node to out using the default Config.
 func Fprint(out io.Writer, fset *token.FileSet, node interface{}) error {
-	var p printer
+	return NewConfig().Fprint(out, fset, node)
+}
 
-	// default values for Config.
-	p.ImpliedSemi = true
-	p.IndentWidth = 4
-	p.NewlineChar = []byte("\n")
+// Fprint "pretty-prints" an AST node to out using the layout of cfg.
+func (cfg *Config) Fprint(out io.Writer, fset *token.FileSet, node interface{}) error {
+	var p printer
+	p.Config = *cfg
 
 	// set printer fields.
 	p.fset = fset
@@ -36,6 +58,15 @@ func Fprint(out io.Writer, fset *token.FileSet, node interface{}) error {
 	if err := p.printNode(node); err != nil {
 		return err
 	}
+	if p.err != nil {
+		return p.err
+	}
+
+	if f, ok := node.(*ast.File); ok && cfg.Safe {
+		if err := p.checkSafe(f); err != nil {
+			return err
+		}
+	}
 
 	if _, err := out.Write(p.output); err != nil {
 		return err
@@ -43,99 +74,853 @@ func Fprint(out io.Writer, fset *token.FileSet, node interface{}) error {
 	return nil
 }
 
+// checkSafe parses the output printed for f and reports an error if
+// its tree differs from f in anything but positions.
+func (p *printer) checkSafe(f *ast.File) error {
+	fset := p.fset
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
+	filename := "<output>"
+	if pos := fset.Position(f.Pos()); pos.Filename != "" {
+		filename = pos.Filename
+	}
+	res, err := parser.ParseFile(token.NewFileSet(), filename, p.output)
+	if err != nil {
+		return fmt.Errorf("safe: formatted source does not parse: %v", err)
+	}
+	for i, stmt := range f.Stmts {
+		if i >= len(res.Stmts) {
+			return fmt.Errorf("%s: safe: statement is missing from the formatted source", fset.Position(stmt.Pos()))
+		}
+		if !ast.Equal(stmt, res.Stmts[i]) {
+			return fmt.Errorf("%s: safe: formatting changed the meaning of the statement", fset.Position(stmt.Pos()))
+		}
+	}
+	if len(res.Stmts) > len(f.Stmts) {
+		return fmt.Errorf("safe: formatted source has %d statements, source has %d", len(res.Stmts), len(f.Stmts))
+	}
+	if len(res.Comments) != len(f.Comments) {
+		return fmt.Errorf("%s: safe: formatting changed the comments", filename)
+	}
+	for i, g := range f.Comments {
+		if !ast.Equal(g, res.Comments[i]) {
+			return fmt.Errorf("%s: safe: formatting changed the comment", fset.Position(g.Pos()))
+		}
+	}
+	return nil
+}
+
 func (p *printer) printNode(node interface{}) error {
 	switch n := node.(type) {
-	case ast.SelectStmt:
-		p.selectStmt(n)
+	case *ast.File:
+		p.comments = n.Comments
+		return p.file(n)
+	case *CommentedNode:
+		p.comments = n.Comments
+		if f, ok := n.Node.(*ast.File); ok {
+			return p.file(f)
+		}
+		x, ok := n.Node.(ast.Node)
+		if !ok {
+			return fmt.Errorf("sqlfmt/printer: unsupported node type %T", n.Node)
+		}
+		p.leadComments(x.Pos())
+		if err := p.printNode(x); err != nil {
+			return err
+		}
+		p.trailingComments(x.End(), infinity)
+		if p.cindex < len(p.comments) {
+			p.endLine()
+			p.leadComments(infinity)
+		}
+		return nil
+	case ast.SelectStmt, ast.SetOpStmt, ast.ParenStmt:
+		p.query(n.(ast.Stmt), infinity)
+		p.insertSemi()
 		return nil
+	case ast.Expr:
+		p.expr(n)
+		return nil
+	default:
+		return fmt.Errorf("sqlfmt/printer: unsupported node type %T", node)
+	}
+}
+
+// file prints the statements of f one after another. A blank line
+// between two statements in the source is kept as a single blank line.
+func (p *printer) file(f *ast.File) error {
+	for i, stmt := range f.Stmts {
+		if i > 0 {
+			p.endLine()
+			if p.lineDistance(stmtEnd(f, i-1), p.nextPos(stmt.Pos())) > 1 {
+				p.appendNewline()
+			}
+		}
+		p.leadComments(stmt.Pos())
+		if err := p.printNode(stmt); err != nil {
+			return err
+		}
+		p.trailingComments(stmt.End(), infinity)
+	}
+	if len(f.Stmts) > 0 {
+		p.endLine()
+		if p.lineDistance(stmtEnd(f, len(f.Stmts)-1), p.nextPos(infinity)) > 1 {
+			p.appendNewline()
+		}
+	}
+	p.leadComments(infinity)
+	return nil
+}
+
+// stmtEnd returns the end of the ith statement of f, or the position of
+// the last semicolon after it.
+func stmtEnd(f *ast.File, i int) token.Pos {
+	if i < len(f.Semicolons) && f.Semicolons[i] != token.NoPos {
+		return f.Semicolons[i]
+	}
+	return f.Stmts[i].End()
+}
+
+// lineDistance returns the number of lines from the line of pos1 to the
+// line of pos2 in the source. It returns 0 if the source is unknown.
+func (p *printer) lineDistance(pos1, pos2 token.Pos) int {
+	if p.fset == nil {
+		return 0
+	}
+	file := p.fset.File(pos1)
+	if file == nil || file != p.fset.File(pos2) {
+		return 0
+	}
+	return file.Line(pos2) - file.Line(pos1)
+}
+
+// sameLine reports whether pos1 and pos2 are known to be on the same
+// line of the source.
+func (p *printer) sameLine(pos1, pos2 token.Pos) bool {
+	if p.fset == nil {
+		return false
+	}
+	file := p.fset.File(pos1)
+	return file != nil && file == p.fset.File(pos2) && file.Line(pos1) == file.Line(pos2)
+}
+
+// nextPos returns the position of the next comment group if it comes
+// before pos, or pos otherwise.
+func (p *printer) nextPos(pos token.Pos) token.Pos {
+	if p.cindex < len(p.comments) && p.comments[p.cindex].Pos() < pos {
+		return p.comments[p.cindex].Pos()
+	}
+	return pos
+}
+
+// leadComments prints the comment groups starting before pos on lines
+// of their own at the current indentation. Comments which were on one
+// line stay on one line. A blank line after a comment group in the
+// source is kept.
+func (p *printer) leadComments(pos token.Pos) {
+	for p.cindex < len(p.comments) && p.comments[p.cindex].Pos() < pos {
+		g := p.comments[p.cindex]
+		p.cindex++
+		for i, c := range g.List {
+			if i > 0 {
+				if p.breaksBefore(g.List[i-1], c) {
+					p.appendNewline()
+				} else {
+					p.output = append(p.output, ' ')
+				}
+			}
+			p.comment(c)
+		}
+		p.appendNewline()
+		if p.lineDistance(g.End(), p.nextPos(pos)) > 1 {
+			p.appendNewline()
+		}
+	}
+}
+
+// trailingComments prints the comment groups starting before end, which
+// were inside the node ending at end, and the comment groups starting
+// before limit on the line where the node ends, after the node on the
+// current line. limit is the position of the next node. Comments which
+// were on one line stay on one line, and a -- comment ends it.
+func (p *printer) trailingComments(end, limit token.Pos) {
+	var prev *ast.Comment
+	for p.cindex < len(p.comments) {
+		g := p.comments[p.cindex]
+		if g.Pos() >= end && (g.Pos() >= limit || !p.sameLine(end, g.Pos())) {
+			return
+		}
+		p.cindex++
+		for _, c := range g.List {
+			if p.breaksBefore(prev, c) {
+				p.appendNewline()
+			} else if !p.atLineStart() {
+				p.output = append(p.output, ' ')
+			}
+			p.comment(c)
+			prev = c
+		}
+	}
+}
+
+// exprComments prints the comment groups starting before pos where they
+// fall inside an expression. Comments which were on one line stay on one
+// line, and a -- comment ends it.
+func (p *printer) exprComments(pos token.Pos) {
+	if p.cindex >= len(p.comments) || p.comments[p.cindex].Pos() >= pos {
+		return
+	}
+	var prev *ast.Comment
+	for p.cindex < len(p.comments) && p.comments[p.cindex].Pos() < pos {
+		g := p.comments[p.cindex]
+		p.cindex++
+		for _, c := range g.List {
+			if p.breaksBefore(prev, c) {
+				p.appendNewline()
+			} else {
+				p.blank()
+			}
+			p.comment(c)
+			prev = c
+		}
+	}
+	if p.lineComment {
+		p.appendNewline()
+	} else {
+		p.print(" ")
+	}
+}
+
+// commaComments prints the comment groups starting before end, and those
+// starting before next on the line where the list item ending at end
+// ends, so that they stay before the comma after the item. next is the
+// position of the next item. A group with a -- comment is left for after
+// the comma.
+func (p *printer) commaComments(end, next token.Pos) {
+	limit := next
+	for _, g := range p.comments[p.cindex:] {
+		if g.Pos() >= next {
+			break
+		}
+		if g.Pos() >= end && hasLineComment(g) {
+			limit = g.Pos()
+			break
+		}
+	}
+	p.trailingComments(end, limit)
+}
+
+// hasLineComment reports whether g contains a -- comment.
+func hasLineComment(g *ast.CommentGroup) bool {
+	for _, c := range g.List {
+		if strings.HasPrefix(c.Text, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// breaksBefore reports whether the comment c starts a new line of the
+// output: after a -- comment, or if c didn't start on the line of the
+// comment prev in the source. prev is nil if c follows a token.
+func (p *printer) breaksBefore(prev, c *ast.Comment) bool {
+	return p.lineComment || prev != nil && !p.sameLine(prev.End(), c.Pos())
+}
+
+// comment writes the text of c to the output.
+func (p *printer) comment(c *ast.Comment) {
+	p.output = append(p.output, c.Text...)
+	p.lineComment = strings.HasPrefix(c.Text, "--")
+}
+
+// query prints a select statement or a compound query. next is the
+// position of the node following the query.
+func (p *printer) query(node ast.Stmt, next token.Pos) {
+	switch n := node.(type) {
+	case ast.SelectStmt:
+		p.selectStmt(n, next)
+	case ast.SetOpStmt:
+		p.setOpStmt(n, next)
+	case ast.ParenStmt:
+		p.parenStmt(n, next)
 	default:
-		return fmt.Errorf("gofmt/ast: unsupported node type %T", node)
+		panic(fmt.Sprintf("printer: unsupported query type %T", node))
 	}
 }
 
-func (p *printer) selectStmt(node ast.SelectStmt) {
-	p.selectClause(node.Select)
+// setOpStmt prints the set operator of a compound query on its own line
+// between the queries it combines.
+func (p *printer) setOpStmt(node ast.SetOpStmt, next token.Pos) {
+	if node.With.Exists {
+		p.withClause(node.With, node.X.Pos())
+	}
+
+	p.query(node.X, node.OpPos)
+
+	op := node.Op.String()
+	switch {
+	case node.All:
+		op += " " + token.ALL.String()
+	case node.Distinct:
+		op += " " + token.DISTINCT.String()
+	}
+	p.leadComments(node.OpPos)
+	p.print(op)
+	p.trailingComments(node.OpPos+token.Pos(len(node.Op.String())), node.Y.Pos())
+	p.appendNewline()
 
-	p.fromClause(node.From)
+	p.query(node.Y, firstPos(node.Orderby.Pos(), node.Limit.Pos(), node.Offset.Pos(), node.Fetch.Pos(), next))
 
-	p.insertSemi()
+	p.queryTail(node.Orderby, node.Limit, node.Offset, node.Fetch, next)
 }
 
-func (p *printer) selectClause(node ast.SelectClause) {
-	// Write SELECT keyword
-	p.output = append(p.output, []byte(token.SELECT.String())...)
+// parenStmt prints a parenthesized query indented one level deeper than
+// its parentheses, which are on lines of their own.
+func (p *printer) parenStmt(node ast.ParenStmt, next token.Pos) {
+	if node.With.Exists {
+		p.withClause(node.With, node.Lparen)
+	}
+
+	tail := firstPos(node.Orderby.Pos(), node.Limit.Pos(), node.Offset.Pos(), node.Fetch.Pos(), next)
+
+	p.leadComments(node.Lparen)
+	p.print(token.LPAREN.String())
+	p.trailingComments(node.Lparen+1, node.Query.Pos())
 	p.indent++
 	p.appendNewline()
+	p.query(node.Query, node.Rparen)
+	p.leadComments(node.Rparen)
+	p.indent--
+	p.reindent()
+	p.print(token.RPAREN.String())
+	p.trailingComments(node.Rparen+1, tail)
+	p.appendNewline()
+
+	p.queryTail(node.Orderby, node.Limit, node.Offset, node.Fetch, next)
+}
+
+// selectStmt prints the clauses of a select statement, each starting on
+// a new line. next is the position of the node following the statement.
+func (p *printer) selectStmt(node ast.SelectStmt, next token.Pos) {
+	if node.With.Exists {
+		p.withClause(node.With, node.Select.Pos())
+	}
+
+	p.selectClause(node.Select, node.From.Pos())
+
+	tail := firstPos(node.Orderby.Pos(), node.Limit.Pos(), node.Offset.Pos(), node.Fetch.Pos(), next)
+
+	p.fromClause(node.From, firstPos(node.Where.Pos(), node.Groupby.Pos(), node.Having.Pos(), tail))
 
-	p.columnList(node.Cols)
+	if node.Where.Exists {
+		p.condClause(node.Where.Pos(), token.WHERE, node.Where.CondExpr, firstPos(node.Groupby.Pos(), node.Having.Pos(), tail))
+	}
+
+	if node.Groupby.Exists {
+		p.keyword(node.Groupby.Pos(), token.GROUP.String()+" "+token.BY.String())
+		p.exprLines(node.Groupby.Groups, firstPos(node.Having.Pos(), tail))
+	}
+
+	if node.Having.Exists {
+		p.condClause(node.Having.Pos(), token.HAVING, node.Having.CondExpr, tail)
+	}
 
+	p.queryTail(node.Orderby, node.Limit, node.Offset, node.Fetch, next)
 }
 
-func (p *printer) fromClause(node ast.FromClause) {
-	p.output = append(
-		p.output,
-		[]byte(token.FROM.String())...,
-	)
+// queryTail prints the ORDER BY clause followed by the LIMIT, OFFSET and
+// FETCH clauses, each on a single line in the order of the source.
+func (p *printer) queryTail(orderby ast.OrderbyClause, limit ast.LimitClause, offset ast.OffsetClause, fetch ast.FetchClause, next token.Pos) {
+	if orderby.Exists {
+		p.keyword(orderby.Pos(), token.ORDER.String()+" "+token.BY.String())
+		p.orderLines(orderby.Orders, firstPos(limit.Pos(), offset.Pos(), fetch.Pos(), next))
+	}
+
+	clauses := []ast.Clause{limit, offset, fetch}
+	sort.Slice(clauses, func(i, j int) bool { return clauses[i].Pos() < clauses[j].Pos() })
+	for i, c := range clauses {
+		if c.Pos() == token.NoPos {
+			continue
+		}
+		p.leadComments(c.Pos())
+		switch c := c.(type) {
+		case ast.LimitClause:
+			p.print(token.LIMIT.String() + " ")
+			p.expr(c.Count)
+		case ast.OffsetClause:
+			p.print(token.OFFSET.String() + " ")
+			p.expr(c.Offset)
+			if c.Rows != "" {
+				p.print(" " + c.Rows)
+			}
+		case ast.FetchClause:
+			p.fetchClause(c)
+		}
+		to := next
+		if i < len(clauses)-1 {
+			to = clauses[i+1].Pos()
+		}
+		p.trailingComments(c.End(), to)
+		p.appendNewline()
+	}
+}
+
+func (p *printer) fetchClause(node ast.FetchClause) {
+	p.print(token.FETCH.String() + " " + node.First + " ")
+	if node.Count != nil {
+		p.expr(node.Count)
+		p.print(" ")
+	}
+	p.print(node.Rows + " ")
+	if node.WithTies {
+		p.print(token.WITH.String() + " TIES")
+	} else {
+		p.print("ONLY")
+	}
+}
+
+// subquery prints the select statement of a subquery indented one level
+// deeper than its parentheses.
+func (p *printer) subquery(s ast.SubqueryExpr) {
+	p.print(token.LPAREN.String())
+	p.indent++
+	p.appendNewline()
+	p.query(s.Query, s.Rparen)
+	p.leadComments(s.Rparen)
+	p.indent--
+	p.reindent()
+	p.print(token.RPAREN.String())
+}
+
+// firstPos returns the smallest valid position of list, or infinity if
+// there is none.
+func firstPos(list ...token.Pos) token.Pos {
+	first := infinity
+	for _, pos := range list {
+		if pos != token.NoPos && pos < first {
+			first = pos
+		}
+	}
+	return first
+}
+
+// keyword prints the keyword of the clause starting at pos on its own
+// line and indents the lines of the clause body.
+func (p *printer) keyword(pos token.Pos, kw string) {
+	p.leadComments(pos)
+	p.print(kw)
 	p.indent++
 	p.appendNewline()
+}
 
-	p.tableList(node.Tables)
+// withClause prints the common table expressions one after another,
+// separated by a blank line.
+func (p *printer) withClause(node ast.WithClause, next token.Pos) {
+	kw := token.WITH.String()
+	if node.Recursive {
+		kw += " " + token.RECURSIVE.String()
+	}
+	p.keyword(node.Pos(), kw)
 
+	for i, c := range node.CTEs {
+		if i > 0 {
+			p.appendNewline()
+		}
+		p.leadComments(c.Pos())
+		p.print(c.Name + " ")
+		if len(c.Cols) > 0 {
+			p.identList(c.Cols, token.NoPos)
+			p.print(" ")
+		}
+		p.print(token.ALIAS.String() + " ")
+		if c.NotMaterialized {
+			p.print(token.NOT.String() + " ")
+		}
+		if c.Materialized || c.NotMaterialized {
+			p.print(token.MATERIALIZED.String() + " ")
+		}
+		p.subquery(c.Query)
+
+		limit := next
+		if i < len(node.CTEs)-1 {
+			p.print(token.COMMA.String())
+			limit = node.CTEs[i+1].Pos()
+		} else {
+			p.indent--
+		}
+		p.trailingComments(c.End(), limit)
+		p.appendNewline()
+	}
 }
 
-func (p *printer) columnList(node []*ast.Column) {
-	for i, v := range node {
-		switch n := v.Value.(type) {
-		case ast.BasicLit:
-			expr := []byte(n.Kind.String())
-			p.output = append(
-				p.output,
-				expr...,
-			)
-			p.outputPos.Column += utf8.RuneCount(expr)
+// identList prints a parenthesized list of column names. rparen is the
+// position of the closing parenthesis, or NoPos if it isn't known.
+func (p *printer) identList(list []ast.Ident, rparen token.Pos) {
+	p.print(token.LPAREN.String())
+	for i, x := range list {
+		if i > 0 {
+			p.commaComments(list[i-1].End(), x.Pos())
+			p.print(token.COMMA.String() + " ")
+		}
+		p.expr(x)
+	}
+	p.trailingComments(rparen, rparen)
+	p.print(token.RPAREN.String())
+}
+
+func (p *printer) selectClause(node ast.SelectClause, next token.Pos) {
+	kw := token.SELECT.String()
+	if node.Distinct {
+		kw += " " + token.DISTINCT.String()
+	}
+	p.leadComments(node.Pos())
+	p.print(kw)
+	if len(node.DistinctOn) > 0 {
+		p.print(" " + token.ON.String() + " " + token.LPAREN.String())
+		p.exprList(node.DistinctOn)
+		p.print(token.RPAREN.String())
+	}
+	if node.Top != nil {
+		p.print(" " + token.TOP.String() + " ")
+		// TOP takes a primary expression
+		p.expr1(node.Top, token.HighestPrec)
+	}
+	p.indent++
+	p.appendNewline()
+
+	p.columnList(node.Cols, next)
+
+}
+
+func (p *printer) fromClause(node ast.FromClause, next token.Pos) {
+	p.keyword(node.Pos(), token.FROM.String())
+
+	p.tableList(node.Tables, next)
+
+}
+
+// condClause prints the WHERE or HAVING clause starting at pos with the
+// search condition cond.
+func (p *printer) condClause(pos token.Pos, kw token.Token, cond ast.Expr, next token.Pos) {
+	p.keyword(pos, kw.String())
+
+	p.condition(cond)
+	p.indent--
+	p.trailingComments(cond.End(), next)
+	p.appendNewline()
+}
+
+// condition prints a search condition. Unless Config.OneLineCondition is
+// set, the operands of a chain of AND and OR operators are printed one
+// per line, with the operator at the start of the line, or at the end of
+// the previous line if Config.TrailingLogicalOp is set.
+func (p *printer) condition(x ast.Expr) {
+	if p.RemoveParens {
+		for {
+			paren, ok := x.(ast.ParenExpr)
+			if !ok {
+				break
+			}
+			x = paren.X
+		}
+	}
+	if p.OneLineCondition {
+		p.expr(x)
+		return
+	}
+	if b, ok := logicalChain(x); ok {
+		p.condChain(b, false)
+		return
+	}
+	p.condOperand(x, token.LowestPrec)
+}
+
+// condChain prints the operands of the chain of AND or OR operators b
+// one per line. If nested is set, the lines after the first one are
+// indented one level deeper.
+func (p *printer) condChain(b ast.BinaryExpr, nested bool) {
+	links := chainLinks(b)
+	prec := b.Op.Precedence()
+	p.condOperand(links[0].X, prec)
+	if nested {
+		p.indent++
+	}
+	for _, l := range links {
+		if p.TrailingLogicalOp {
+			p.blank()
+			p.print(l.Op.String())
+			p.exprComments(l.Y.Pos())
+			p.endLine()
+		} else {
+			p.exprComments(l.OpPos)
+			p.endLine()
+			p.print(l.Op.String() + " ")
+		}
+		p.condOperand(l.Y, prec+1)
+	}
+	if nested {
+		p.indent--
+	}
+}
+
+// condOperand prints an operand of a condition with the precedence
+// prec1. An operand which is itself a chain of AND or OR operators is
+// split the same way. A parenthesized chain is printed one level deeper
+// than its parentheses, which end the first and start the last line.
+func (p *printer) condOperand(x ast.Expr, prec1 int) {
+	p.exprComments(x.Pos())
+	if paren, ok := x.(ast.ParenExpr); ok && !p.RemoveParens {
+		if b, ok := logicalChain(paren.X); ok {
+			p.parenChain(b)
+			return
+		}
+	}
+	y := x
+	if p.RemoveParens {
+		for {
+			paren, ok := y.(ast.ParenExpr)
+			if !ok {
+				break
+			}
+			y = paren.X
+		}
+	}
+	b, ok := logicalChain(y)
+	switch {
+	case !ok:
+		p.expr1(x, prec1)
+	case b.Op.Precedence() < prec1:
+		p.parenChain(b)
+	default:
+		p.condChain(b, true)
+	}
+}
+
+// parenChain prints the chain b in parentheses with its operands indented
+// one level deeper.
+func (p *printer) parenChain(b ast.BinaryExpr) {
+	p.print(token.LPAREN.String())
+	p.indent++
+	p.appendNewline()
+	p.condChain(b, false)
+	p.indent--
+	p.appendNewline()
+	p.print(token.RPAREN.String())
+}
+
+// logicalChain reports whether x is a chain of AND or OR operators.
+func logicalChain(x ast.Expr) (ast.BinaryExpr, bool) {
+	b, ok := x.(ast.BinaryExpr)
+	return b, ok && (b.Op == token.AND || b.Op == token.OR)
+}
+
+// chainLinks returns the operators of the chain of b.Op operators b from
+// left to right. The first operand of the chain is the X of the first
+// one.
+func chainLinks(b ast.BinaryExpr) []ast.BinaryExpr {
+	var list []ast.BinaryExpr
+	if x, ok := b.X.(ast.BinaryExpr); ok && x.Op == b.Op {
+		list = chainLinks(x)
+	}
+	return append(list, b)
+}
 
-			p.alias(v.Alias)
+// exprLines prints the expressions one per line and ends the indented
+// clause body. next is the position of the node following the list.
+func (p *printer) exprLines(list []ast.Expr, next token.Pos) {
+	for i, x := range list {
+		p.leadComments(x.Pos())
+		p.expr(x)
+		limit := next
+		if i < len(list)-1 {
+			p.commaComments(x.End(), list[i+1].Pos())
+			p.print(token.COMMA.String())
+			limit = list[i+1].Pos()
+		} else {
+			p.indent--
+		}
+		p.trailingComments(x.End(), limit)
+		p.appendNewline()
+	}
+}
+
+// orderLines is like exprLines for the items of ORDER BY. The sort order
+// stays on the line of its expression.
+func (p *printer) orderLines(list []ast.OrderItem, next token.Pos) {
+	for i, o := range list {
+		p.leadComments(o.Pos())
+		p.orderItem(o)
+		limit := next
+		if i < len(list)-1 {
+			p.commaComments(o.End(), list[i+1].Pos())
+			p.print(token.COMMA.String())
+			limit = list[i+1].Pos()
+		} else {
+			p.indent--
 		}
+		p.trailingComments(o.End(), limit)
+		p.appendNewline()
+	}
+}
+
+func (p *printer) orderItem(o ast.OrderItem) {
+	p.expr(o.X)
+	if o.Collate != "" {
+		p.exprComments(o.CollatePos)
+		p.blank()
+		p.print(token.COLLATE.String() + " " + o.Collate)
+	}
+	if o.Dir != token.ILLEGAL {
+		p.exprComments(o.DirPos)
+		p.blank()
+		p.print(o.Dir.String())
+	}
+	if o.Nulls != "" {
+		p.exprComments(o.NullsPos)
+		p.blank()
+		p.print("NULLS " + o.Nulls)
+	}
+}
+
+// columnList prints the columns one per line. next is the position of
+// the node following the list.
+func (p *printer) columnList(node []*ast.Column, next token.Pos) {
+	for i, v := range node {
+		p.leadComments(v.Pos())
+		p.expr(v.Value)
+		p.alias(v.AsPos, v.End(), v.Alias, p.ColumnAs)
 
 		// when there are columns and v in this loop is not last, add camma.
 		if i < len(node)-1 {
-			p.output = append(p.output, []byte(",")...)
+			p.commaComments(v.End(), node[i+1].Pos())
+			p.print(token.COMMA.String())
 		} else if i == len(node)-1 { // when v is last column, adjust indent.
 
 			p.indent--
 		}
+		limit := next
+		if i < len(node)-1 {
+			limit = node[i+1].Pos()
+		}
+		p.trailingComments(v.End(), limit)
 		p.appendNewline()
 	}
 }
 
-func (p *printer) alias(name string) {
-	if name != "" {
-		// add alias string
+// alias prints the alias name, with AS as style requires. asPos is the
+// position of AS in the source, and end the end of the name.
+func (p *printer) alias(asPos, end token.Pos, name string, style AliasStyle) {
+	if name == "" {
+		return
 	}
+	p.exprComments(asPos)
+	p.blank()
+	switch {
+	case style == AddAs,
+		style == PreserveAs && asPos != token.NoPos,
+		style == RemoveAs && !token.CanOmitAs(name):
+		p.print(token.ALIAS.String() + " ")
+	}
+	p.exprComments(end - token.Pos(len(name)))
+	p.print(name)
 }
 
-func (p *printer) tableList(tables []*ast.Table) {
+// tableList prints the tables one per line. next is the position of
+// the node following the list.
+func (p *printer) tableList(tables []*ast.Table, next token.Pos) {
 	for i, v := range tables {
-		switch n := v.Value.(type) {
-		case ast.TableBasicLit:
-			p.output = append(
-				p.output,
-				[]byte(n.Name)...,
-			)
-			p.alias(v.Alias)
-		}
+		p.leadComments(v.Pos())
+		p.table(v)
 		// when there are columns and v in this loop is not last, add camma.
 		if i < len(tables)-1 {
-			p.output = append(p.output, []byte(",")...)
+			p.commaComments(v.End(), tables[i+1].Pos())
+			p.print(token.COMMA.String())
 		} else if i == len(tables)-1 { // when v is last column, adjust indent.
 
 			p.indent--
 		}
+		limit := next
+		if i < len(tables)-1 {
+			limit = tables[i+1].Pos()
+		}
+		p.trailingComments(v.End(), limit)
 		p.appendNewline()
 	}
 }
 
+func (p *printer) table(t *ast.Table) {
+	p.exprComments(t.Pos())
+	switch n := t.Value.(type) {
+	case ast.TableBasicLit:
+		p.print(n.Name)
+	case ast.QualifiedName:
+		p.qualifiedName(n)
+	case ast.SubqueryExpr:
+		p.subquery(n)
+	case ast.JoinExpr:
+		p.joinExpr(n)
+	}
+	p.alias(t.AsPos, t.End(), t.Alias, p.TableAs)
+}
+
+// joinExpr prints each join of a chain of joins on its own line, with
+// its ON or USING on the next line indented one more level. Comments
+// on the line of a table stay after it.
+func (p *printer) joinExpr(j ast.JoinExpr) {
+	p.table(j.Left)
+	p.trailingComments(j.Left.End(), j.JoinPos)
+	p.appendNewline()
+	p.leadComments(j.JoinPos)
+
+	var kw []string
+	if j.Natural {
+		kw = append(kw, token.NATURAL.String())
+	}
+	if j.Kind != token.JOIN {
+		kw = append(kw, j.Kind.String())
+	}
+	if j.Outer {
+		kw = append(kw, token.OUTER.String())
+	}
+	kw = append(kw, token.JOIN.String())
+	p.print(strings.Join(kw, " ") + " ")
+	p.table(j.Right)
+
+	switch {
+	case j.Cond != nil:
+		p.trailingComments(j.Right.End(), j.OnPos)
+		p.indent++
+		p.appendNewline()
+		p.leadComments(j.OnPos)
+		p.print(token.ON.String() + " ")
+		p.condition(j.Cond)
+		p.indent--
+	case j.UsingPos != token.NoPos:
+		p.trailingComments(j.Right.End(), j.UsingPos)
+		p.indent++
+		p.appendNewline()
+		p.leadComments(j.UsingPos)
+		p.print(token.USING.String() + " ")
+		p.identList(j.Using, j.Rparen)
+		p.indent--
+	}
+}
+
+// print writes s to the output. s must not contain newlines.
+func (p *printer) print(s string) {
+	if p.lineComment {
+		p.appendNewline()
+	}
+	p.output = append(p.output, s...)
+	p.outputPos.Column += utf8.RuneCountInString(s)
+}
+
+// appendNewline ends the current line without trailing whitespace and
+// indents the new line.
 func (p *printer) appendNewline() {
+	p.lineComment = false
+	p.output = bytes.TrimRight(p.output, " ")
 	p.output = append(p.output, p.NewlineChar...)
 	p.outputPos.Line++
 	p.outputPos.Column = 1 + p.indent*p.IndentWidth
@@ -147,10 +932,41 @@ func (p *printer) appendNewline() {
 	)
 }
 
+// reindent indents the current line, to which nothing has been written
+// yet, with the current indentation.
+func (p *printer) reindent() {
+	p.output = bytes.TrimRight(p.output, " ")
+	p.outputPos.Column = 1 + p.indent*p.IndentWidth
+	p.output = append(p.output, strings.Repeat(" ", p.outputPos.Column-1)...)
+}
+
+// endLine ends the current line unless nothing has been written to it,
+// which is the case after a statement printed without a semicolon.
+func (p *printer) endLine() {
+	if !p.atLineStart() {
+		p.appendNewline()
+	}
+}
+
+func (p *printer) atLineStart() bool {
+	out := bytes.TrimRight(p.output, " ")
+	return len(out) == 0 || bytes.HasSuffix(out, p.NewlineChar)
+}
+
 func (p *printer) insertSemi() {
 	if p.ImpliedSemi {
-		p.output = append(p.output, []byte(";")...)
-		p.outputPos.Column++
+		p.print(";")
+	}
+}
+
+// blank writes a space unless the current line is empty or ends with a
+// space or an opening parenthesis.
+func (p *printer) blank() {
+	if p.atLineStart() {
+		return
+	}
+	if c := p.output[len(p.output)-1]; c != ' ' && c != '(' {
+		p.print(" ")
 	}
 }
 
@@ -159,4 +975,34 @@ type Config struct {
 	ImpliedSemi bool // ImpliedSemi control end of statement semicolon.
 	IndentWidth int
 	NewlineChar []byte
+
+	OneLineCondition  bool // print AND/OR chains of a condition on one line
+	TrailingLogicalOp bool // put AND/OR at the end of the line instead of its start
+	RemoveParens      bool // remove parentheses which the precedence of operators makes redundant
+
+	ColumnAs AliasStyle // AS before column aliases
+	TableAs  AliasStyle // AS before table aliases
+
+	// Safe makes Fprint parse the output printed for an *ast.File and
+	// fail if its tree differs from the printed one other than in
+	// positions, so formatting can't change what the source means.
+	Safe bool
+}
+
+// AliasStyle controls whether AS is printed before an alias.
+type AliasStyle int
+
+const (
+	PreserveAs AliasStyle = iota // print AS where the source has it
+	AddAs                        // always print AS
+	RemoveAs                     // omit AS
+)
+
+// NewConfig returns a Config with the default layout.
+func NewConfig() *Config {
+	return &Config{
+		ImpliedSemi: true,
+		IndentWidth: 4,
+		NewlineChar: []byte("\n"),
+	}
 }
@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 
+	"github.com/Neetless/sqlfmt/ast"
 	"github.com/Neetless/sqlfmt/parser"
 	"github.com/Neetless/sqlfmt/token"
 
@@ -17,10 +18,825 @@ type testSQLSet struct {
 
 func TestFprint(t *testing.T) {
 	testSet := []testSQLSet{
-		testSQLSet{},
+		testSQLSet{
+			// the formatted source, with semicolons on their own lines
+			input:  []byte("SELECT\n    a\nFROM\n    t\n;\nSELECT\n    b\nFROM\n    u\n;\n\nSELECT\n    c\nFROM\n    v\n;\n-- end\n"),
+			expect: "SELECT\n    a\nFROM\n    t\n;\nSELECT\n    b\nFROM\n    u\n;\n\nSELECT\n    c\nFROM\n    v\n;\n-- end\n",
+		},
+		testSQLSet{
+			input: []byte(`select * from t1; select * from t2`),
+			expect: `SELECT
+    *
+FROM
+    t1
+;
+SELECT
+    *
+FROM
+    t2
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select * from t1;
+
+
+select * from t2;
+select * from t3;`),
+			expect: `SELECT
+    *
+FROM
+    t1
+;
+
+SELECT
+    *
+FROM
+    t2
+;
+SELECT
+    *
+FROM
+    t3
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`-- header
+
+/* first */
+select * -- all
+from t1; -- done
+
+-- second
+select
+  -- columns
+  *
+from t2
+-- tail
+`),
+			expect: `-- header
+
+/* first */
+SELECT
+    * -- all
+FROM
+    t1 -- done
+;
+
+-- second
+SELECT
+    -- columns
+    *
+FROM
+    t2
+;
+-- tail
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a, t.b as bb, count(*), coalesce(a, -1, 'x'), - -a, a + b * 2,
+case when a > 1 then 'x' when a < 0 then 'y' else 'z' end as c, case a when 1 then 2 end, a is null
+from t as u, v`),
+			expect: `SELECT
+    a,
+    t.b AS bb,
+    count(*),
+    coalesce(a, -1, 'x'),
+    - -a,
+    a + b * 2,
+    CASE
+        WHEN a > 1 THEN 'x'
+        WHEN a < 0 THEN 'y'
+        ELSE 'z'
+    END AS c,
+    CASE a
+        WHEN 1 THEN 2
+    END,
+    a IS NULL
+FROM
+    t AS u,
+    v
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a, count(*) from t where a > 1 and b = 2 and d is null -- cond
+group by a, b order by a, b -- order
+`),
+			expect: `SELECT
+    a,
+    count(*)
+FROM
+    t
+WHERE
+    a > 1
+    AND b = 2
+    AND d IS NULL -- cond
+GROUP BY
+    a,
+    b
+ORDER BY
+    a,
+    b -- order
+;
+`,
+		},
+		testSQLSet{
+			input: []byte("select \"Order ID\", t.`Sum` as [Total Sum], \"a\"\"b\".c from \"Order Details\" as \"T\""),
+			expect: `SELECT
+    "Order ID",
+    t.` + "`Sum`" + ` AS [Total Sum],
+    "a""b".c
+FROM
+    "Order Details" AS "T"
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select 'it''s', E'\n', N'名前', x'FF', $fn$ select 'a'; $fn$ from t`),
+			expect: `SELECT
+    'it''s',
+    E'\n',
+    N'名前',
+    x'FF',
+    $fn$ select 'a'; $fn$
+FROM
+    t
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select ?, $1 from t where a = :a and b = @b`),
+			expect: `SELECT
+    ?,
+    $1
+FROM
+    t
+WHERE
+    a = :a
+    AND b = @b
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select * from a as x inner join b on x.id = b.id and x.k = b.k left outer join c using (id, k)
+cross join d natural join e join f on f.id = 1, g -- g
+where x.id > 1`),
+			expect: `SELECT
+    *
+FROM
+    a AS x
+    INNER JOIN b
+        ON x.id = b.id
+        AND x.k = b.k
+    LEFT OUTER JOIN c
+        USING (id, k)
+    CROSS JOIN d
+    NATURAL JOIN e
+    JOIN f
+        ON f.id = 1,
+    g -- g
+WHERE
+    x.id > 1
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select (select max(b) from u) as m from (select a from v) as d
+where exists (select 1 from w where w.a = d.a) and a not in (select a from x -- x
+) and b in (1, 2) and not c = 1 or a > all (select a from y)`),
+			expect: `SELECT
+    (
+        SELECT
+            max(b)
+        FROM
+            u
+    ) AS m
+FROM
+    (
+        SELECT
+            a
+        FROM
+            v
+    ) AS d
+WHERE
+    EXISTS (
+        SELECT
+            1
+        FROM
+            w
+        WHERE
+            w.a = d.a
+    )
+        AND a NOT IN (
+            SELECT
+                a
+            FROM
+                x -- x
+        )
+        AND b IN (1, 2)
+        AND NOT c = 1
+    OR a > ALL (
+        SELECT
+            a
+        FROM
+            y
+    )
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`with recursive r (n) as (select 1 from t), -- r
+s as not materialized (select n from r), u as materialized (select n from s) select * from u`),
+			expect: `WITH RECURSIVE
+    r (n) AS (
+        SELECT
+            1
+        FROM
+            t
+    ), -- r
+
+    s AS NOT MATERIALIZED (
+        SELECT
+            n
+        FROM
+            r
+    ),
+
+    u AS MATERIALIZED (
+        SELECT
+            n
+        FROM
+            s
+    )
+SELECT
+    *
+FROM
+    u
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a from t union all select b from u intersect select c from v -- v
+except distinct select d from w order by 1`),
+			expect: `SELECT
+    a
+FROM
+    t
+UNION ALL
+SELECT
+    b
+FROM
+    u
+INTERSECT
+SELECT
+    c
+FROM
+    v -- v
+EXCEPT DISTINCT
+SELECT
+    d
+FROM
+    w
+ORDER BY
+    1
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`(select a from t union select a from u) intersect ( -- v
+select a from v limit 1) order by a`),
+			expect: `(
+    SELECT
+        a
+    FROM
+        t
+    UNION
+    SELECT
+        a
+    FROM
+        u
+)
+INTERSECT
+( -- v
+    SELECT
+        a
+    FROM
+        v
+    LIMIT 1
+)
+ORDER BY
+    a
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a from t where a = 1 or b = 2 and c = 3 or d = 4`),
+			expect: `SELECT
+    a
+FROM
+    t
+WHERE
+    a = 1
+    OR b = 2
+        AND c = 3
+    OR d = 4
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a from t where a = 1 and (b = 2 or c = 3 and (d = 4 or d = 5)) and e = 6`),
+			expect: `SELECT
+    a
+FROM
+    t
+WHERE
+    a = 1
+    AND (
+        b = 2
+        OR c = 3
+            AND (
+                d = 4
+                OR d = 5
+            )
+    )
+    AND e = 6
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select distinct on (a, b) a, b from t group by a, b having count(a) > 1 and b = 2 order by a offset 5 rows -- o
+fetch first 10 rows only; select top 5 a from t union select b from u limit 3 offset 2`),
+			expect: `SELECT DISTINCT ON (a, b)
+    a,
+    b
+FROM
+    t
+GROUP BY
+    a,
+    b
+HAVING
+    count(a) > 1
+    AND b = 2
+ORDER BY
+    a
+OFFSET 5 ROWS -- o
+FETCH FIRST 10 ROWS ONLY
+;
+SELECT TOP 5
+    a
+FROM
+    t
+UNION
+SELECT
+    b
+FROM
+    u
+LIMIT 3
+OFFSET 2
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select u.*, db."Sales".f(u.id)::pg_catalog.int4 from db."Sales".users as u`),
+			expect: `SELECT
+    u.*,
+    db."Sales".f(u.id)::pg_catalog.int4
+FROM
+    db."Sales".users AS u
+;
+`,
+		},
+		testSQLSet{
+			input: []byte(`select a from t order by a desc nulls last, -- a
+b collate "C" asc, c`),
+			expect: `SELECT
+    a
+FROM
+    t
+ORDER BY
+    a DESC NULLS LAST, -- a
+    b COLLATE "C" ASC,
+    c
+;
+`,
+		},
 	}
-	for i := range testSet {
-		t.Errorf("not implemented yet %d", i)
+	for i, test := range testSet {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test.sql", test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := Fprint(&out, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		test.actual = out.String()
+		if test.actual != test.expect {
+			t.Errorf("%dth Fprint failed. expect:\n%s\nactual:\n%s", i, test.expect, test.actual)
+		}
+	}
+}
+
+func TestConfigFprint(t *testing.T) {
+	src := []byte(`select a from t where a = 1 and b = 2 or c = 3`)
+	tests := []struct {
+		cfg    Config
+		expect string
+	}{
+		{
+			Config{IndentWidth: 2, NewlineChar: []byte("\n"), TrailingLogicalOp: true},
+			`SELECT
+  a
+FROM
+  t
+WHERE
+  a = 1 AND
+    b = 2 OR
+  c = 3
+`,
+		},
+		{
+			Config{ImpliedSemi: true, IndentWidth: 4, NewlineChar: []byte("\n"), OneLineCondition: true},
+			`SELECT
+    a
+FROM
+    t
+WHERE
+    a = 1 AND b = 2 OR c = 3
+;
+`,
+		},
+	}
+	for i, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test.sql", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := test.cfg.Fprint(&out, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expect {
+			t.Errorf("%dth Config.Fprint failed. expect:\n%s\nactual:\n%s", i, test.expect, out.String())
+		}
+	}
+}
+
+func TestConfigFprintAlias(t *testing.T) {
+	src := []byte(`select u.id uid, name as n, a as "for" from users u join items as i on u.id = i.uid, t as left join v on left.id = v.id`)
+	tests := []struct {
+		columnAs, tableAs AliasStyle
+		expect            string
+	}{
+		{PreserveAs, PreserveAs, `SELECT
+    u.id uid,
+    name AS n,
+    a AS "for"
+FROM
+    users u
+    JOIN items AS i
+        ON u.id = i.uid,
+    t AS left
+    JOIN v
+        ON left.id = v.id
+;
+`},
+		{AddAs, RemoveAs, `SELECT
+    u.id AS uid,
+    name AS n,
+    a AS "for"
+FROM
+    users u
+    JOIN items i
+        ON u.id = i.uid,
+    t AS left
+    JOIN v
+        ON left.id = v.id
+;
+`},
+		{RemoveAs, AddAs, `SELECT
+    u.id uid,
+    name n,
+    a "for"
+FROM
+    users AS u
+    JOIN items AS i
+        ON u.id = i.uid,
+    t AS left
+    JOIN v
+        ON left.id = v.id
+;
+`},
+	}
+	for i, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test.sql", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := NewConfig()
+		cfg.ColumnAs, cfg.TableAs = test.columnAs, test.tableAs
+		cfg.Safe = true
+		var out bytes.Buffer
+		if err := cfg.Fprint(&out, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expect {
+			t.Errorf("%dth Config.Fprint failed. expect:\n%s\nactual:\n%s", i, test.expect, out.String())
+		}
+	}
+}
+
+func TestConfigFprintSafe(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Safe = true
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.sql", []byte(`select a -- x
+from t where a = 1 and b = 2`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := cfg.Fprint(&out, fset, f); err != nil {
+		t.Fatalf("safe Fprint of a parsed file failed: %v", err)
+	}
+
+	// an alias which can't be printed back makes the output differ.
+	f.Stmts[0].(ast.SelectStmt).Select.Cols[0].Alias = "b, c"
+	out.Reset()
+	err = cfg.Fprint(&out, fset, f)
+	expect := "test.sql:1:1: safe: formatting changed the meaning of the statement"
+	if err == nil || err.Error() != expect {
+		t.Errorf("safe Fprint must fail. expect: %s, actual: %v", expect, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("safe Fprint must not write output on failure. actual: %q", out.String())
+	}
+}
+
+func TestFprintExpr(t *testing.T) {
+	ident := func(name string) ast.Expr {
+		return ast.Ident{Kind: token.IDENT, Lit: name}
+	}
+	binary := func(x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+		return ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	a, b, c := ident("a"), ident("b"), ident("c")
+
+	tests := []struct {
+		expr   ast.Expr
+		expect string
+	}{
+		{binary(binary(a, token.ADD, b), token.MUL, c), "(a + b) * c"},
+		{binary(a, token.MUL, binary(b, token.ADD, c)), "a * (b + c)"},
+		{binary(binary(a, token.SUB, b), token.SUB, c), "a - b - c"},
+		{binary(a, token.SUB, binary(b, token.SUB, c)), "a - (b - c)"},
+		{binary(a, token.OR, binary(b, token.AND, c)), "a OR b AND c"},
+		{binary(binary(a, token.OR, b), token.AND, c), "(a OR b) AND c"},
+		{ast.UnaryExpr{Op: token.SUB, X: binary(a, token.ADD, b)}, "-(a + b)"},
+		{ast.IsNullExpr{Value: binary(a, token.ADD, b)}, "a + b IS NULL"},
+		{binary(binary(a, token.CONCAT, b), token.EQL, c), "a || b = c"},
+		{binary(binary(a, token.EQL, b), token.CONCAT, c), "(a = b) || c"},
+		{binary(a, token.CONCAT, binary(b, token.ADD, c)), "a || b + c"},
+		{binary(a, token.REM, binary(b, token.QUO, c)), "a % (b / c)"},
+		{binary(a, token.LSSGTR, b), "a <> b"},
+		{ast.UnaryExpr{Op: token.SUB, X: binary(a, token.CAST, ident("int"))}, "-a::int"},
+		{binary(ast.UnaryExpr{Op: token.SUB, X: a}, token.CAST, ident("int")), "(-a)::int"},
+		{binary(binary(a, token.ADD, b), token.CAST, ident("int")), "(a + b)::int"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := Fprint(&out, nil, test.expr); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expect {
+			t.Errorf("Fprint expression failed. expect: %s, actual: %s", test.expect, out.String())
+		}
+	}
+}
+
+func TestFprintParens(t *testing.T) {
+	tests := []struct {
+		src    string
+		keep   string
+		remove string
+	}{
+		{"(a + b) * c", "(a + b) * c", "(a + b) * c"},
+		{"((a)) + (b * c)", "((a)) + (b * c)", "a + b * c"},
+		{"a or (b and c)", "a OR (b AND c)", "a OR b AND c"},
+		{"(a or b) and c", "(a OR b) AND c", "(a OR b) AND c"},
+		{"a - (b - c)", "a - (b - c)", "a - (b - c)"},
+		{"-(-a)", "-(-a)", "- -a"},
+		{"not (a = b)", "NOT (a = b)", "NOT a = b"},
+		{"(a)::int", "(a)::int", "a::int"},
+		{"(a, b) in ((1, 2))", "(a, b) IN ((1, 2))", "(a, b) IN ((1, 2))"},
+		{"-(a is null)", "-(a IS NULL)", "-(a IS NULL)"},
+		{"-(a) is null", "-(a) IS NULL", "-a IS NULL"},
+		{"(a is null) is null", "(a IS NULL) IS NULL", "(a IS NULL) IS NULL"},
+		{"(a + b) is null = (c is null)", "((a + b) IS NULL) = (c IS NULL)", "(a + b IS NULL) = (c IS NULL)"},
+		{"(a = b) is null", "(a = b) IS NULL", "(a = b) IS NULL"},
+		{"x = (y is null)", "x = (y IS NULL)", "x = (y IS NULL)"},
+		{"(a = b) in (1)", "(a = b) IN (1)", "(a = b) IN (1)"},
+		{"a = (b in (1))", "a = (b IN (1))", "a = (b IN (1))"},
+		{"a = b in (1)", "a = (b IN (1))", "a = (b IN (1))"},
+		{"(a in (1)) in (2)", "(a IN (1)) IN (2)", "(a IN (1)) IN (2)"},
+		{"not (a is null)", "NOT (a IS NULL)", "NOT a IS NULL"},
+		{"(a) is not null", "(a) IS NOT NULL", "a IS NOT NULL"},
+		{"(null) = null", "(NULL) = NULL", "NULL = NULL"},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test.sql", []byte("select "+test.src+" from t"))
+		if err != nil {
+			t.Fatalf("%s: %v", test.src, err)
+		}
+		x := f.Stmts[0].(ast.SelectStmt).Select.Cols[0].Value
+		for _, remove := range []bool{false, true} {
+			cfg := NewConfig()
+			cfg.RemoveParens = remove
+			expect := test.keep
+			if remove {
+				expect = test.remove
+			}
+			var out bytes.Buffer
+			if err := cfg.Fprint(&out, fset, x); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != expect {
+				t.Errorf("Fprint %s with RemoveParens %v failed. expect: %s, actual: %s", test.src, remove, expect, out.String())
+			}
+		}
+	}
+}
+
+func TestFprintBadExpr(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.sql", []byte("select a + b from t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := f.Stmts[0].(ast.SelectStmt).Select.Cols[0]
+	x := col.Value.(ast.BinaryExpr)
+	x.Y = ast.BadExpr{From: x.Y.Pos(), To: x.Y.End()}
+	col.Value = x
+
+	var out bytes.Buffer
+	err = Fprint(&out, fset, f)
+	if expect := "test.sql:1:12: cannot print a bad expression"; err == nil || err.Error() != expect {
+		t.Errorf("printing a bad expression must be an error. actual: %v, expect: %s", err, expect)
+	}
+	if out.Len() != 0 {
+		t.Errorf("nothing must be written after an error. actual: %q", out.String())
+	}
+
+	err = Fprint(&out, nil, ast.BadExpr{})
+	if expect := "cannot print a bad expression"; err == nil || err.Error() != expect {
+		t.Errorf("printing a bad expression must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestFprintUnsupportedNode(t *testing.T) {
+	var out bytes.Buffer
+	err := Fprint(&out, nil, 42)
+	if expect := "sqlfmt/printer: unsupported node type int"; err == nil || err.Error() != expect {
+		t.Errorf("printing an unsupported node must be an error. actual: %v, expect: %s", err, expect)
+	}
+}
+
+func TestFprintComments(t *testing.T) {
+	tests := []struct {
+		src    string
+		expect string
+	}{
+		{"select a + -- c1\nb -- c2\nfrom t", `SELECT
+    a + -- c1
+    b -- c2
+FROM
+    t
+;
+`},
+		{"select a from t where /* y */ a = 1 -- cond\n and b = 2", `SELECT
+    a
+FROM
+    t
+WHERE
+    /* y */ a = 1 -- cond
+    AND b = 2
+;
+`},
+		{"select a /* x */ -- c1\n+ 1 from t", `SELECT
+    a /* x */ -- c1
+    + 1
+FROM
+    t
+;
+`},
+		{"select a, /* b */ -- x\n b from t", `SELECT
+    a, /* b */ -- x
+    b
+FROM
+    t
+;
+`},
+		{"/* a */ /* b */\n/* c */\nselect a from t", `/* a */ /* b */
+/* c */
+SELECT
+    a
+FROM
+    t
+;
+`},
+		{"select case -- c1\n when a then 1 /* c2 */ else 2 end from t", `SELECT
+    CASE -- c1
+        WHEN a THEN 1 /* c2 */
+        ELSE 2
+    END
+FROM
+    t
+;
+`},
+		{"select f(a /* c3 */, b), g(a, -- c4\n b) from t", `SELECT
+    f(a /* c3 */, b),
+    g(a, -- c4
+    b)
+FROM
+    t
+;
+`},
+		{"select a from t order by a -- ord\n desc, b /* c5 */, c", `SELECT
+    a
+FROM
+    t
+ORDER BY
+    a -- ord
+    DESC,
+    b /* c5 */,
+    c
+;
+`},
+		{"select a from t /* t1 */ left join u -- u1\n on t.id = u.id join v /* v1 */ using (id)", `SELECT
+    a
+FROM
+    t /* t1 */
+    LEFT JOIN u -- u1
+        ON t.id = u.id
+    JOIN v /* v1 */
+        USING (id)
+;
+`},
+	}
+	for _, test := range tests {
+		src := test.src
+		// formatting the output again must not change it
+		for i := 0; i < 2; i++ {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "test.sql", []byte(src))
+			if err != nil {
+				t.Fatalf("%q: %v", src, err)
+			}
+			cfg := NewConfig()
+			cfg.Safe = true
+			var out bytes.Buffer
+			if err := cfg.Fprint(&out, fset, f); err != nil {
+				t.Fatalf("%q: %v", src, err)
+			}
+			if out.String() != test.expect {
+				t.Errorf("Fprint %q failed. expect:\n%s\nactual:\n%s", src, test.expect, out.String())
+			}
+			src = out.String()
+		}
+	}
+}
+
+func TestFprintCommentedNode(t *testing.T) {
+	src := "select a from t; -- one\n\n/* two */\nselect b -- b\nfrom u; -- three\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.sql", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := f.Stmts[1]
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+	var out bytes.Buffer
+	if err := Fprint(&out, fset, &CommentedNode{Node: stmt, Comments: cmap.Filter(stmt).List()}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `/* two */
+SELECT
+    b -- b
+FROM
+    u -- three
+;`
+	if out.String() != expect {
+		t.Errorf("Fprint CommentedNode failed. expect:\n%s\nactual:\n%s", expect, out.String())
 	}
 }
 
@@ -36,7 +852,8 @@ func TestFprintFromFile(t *testing.T) {
     *
 FROM
     table1
-;`
+;
+`
 	var buf []byte
 	out := bytes.NewBuffer(buf)
 
@@ -3,6 +3,7 @@ package scanner
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -52,6 +53,7 @@ func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler, mode Mode
 		panic(fmt.Sprintf("file size (%d) does not match src len(%d)", file.Size(), len(src)))
 	}
 	s.file = file
+	s.file.SetSource(src)
 	s.dir, _ = filepath.Split(file.Name())
 	s.src = src
 	s.err = err
@@ -70,6 +72,19 @@ func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler, mode Mode
 	}
 }
 
+// Peek returns the next token, skipping comments, without advancing the
+// scanner. Errors in the token are reported when it is scanned by Scan.
+func (s *Scanner) Peek() token.Token {
+	saved := *s
+	defer func() { *s = saved }()
+	s.err = nil
+	for {
+		if _, tok, _ := s.Scan(); tok != token.COMMENT {
+			return tok
+		}
+	}
+}
+
 // Scan scans the next token and returns the token position, the token,
 // and its literal string if applicable.
 func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
@@ -77,12 +92,23 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 	tok = token.ILLEGAL
 	lit = ""
 
+scanAgain:
 	s.skipWhitespace()
 
 	// current token start
-	pos = s.file.Pos(s.offset)
+	offs := s.offset
+	pos = s.file.Pos(offs)
 
 	switch ch := s.ch; {
+	case isStringPrefix(ch) && s.peek() == '\'':
+		tok = token.STRING
+		lit = s.scanPrefixedString()
+	case ch == '$' && s.dollarTag() != "":
+		tok = token.STRING
+		lit = s.scanDollarString()
+	case ch == '?' || isParamPrefix(ch) && s.isParamNext(ch):
+		tok = token.PARAM
+		lit = s.scanParam()
 	case isLetter(ch):
 		lit = s.scanIdentifier()
 		if len(lit) > 1 {
@@ -95,24 +121,42 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 		lit, tok = s.scanNumber()
 	case ch == '\'':
 		tok = token.STRING
-		lit = s.scanString()
+		lit = s.scanString(s.offset, false)
+	case ch == '"' || ch == '`' || ch == '[':
+		tok = token.QUOTED_IDENT
+		lit = s.scanQuotedIdent()
 	default:
 		s.next()
 		switch ch {
 		case -1:
 			tok = token.EOF
-		case '+', '-':
-			// TODO other newline code
-			if s.ch != ' ' && s.ch != '\n' {
-				lit, tok = s.scanNumber()
-				lit = string(ch) + lit
-			} else {
-				tok = token.Lookup(string(ch))
-				lit = string(ch)
+		case '-', '/':
+			if ch == '-' && s.ch == '-' || ch == '/' && s.ch == '*' {
+				lit = s.scanComment(offs)
+				if s.mode&ScanComments == 0 {
+					goto scanAgain
+				}
+				tok = token.COMMENT
+				break
+			}
+			if ch == '/' {
+				tok = token.QUO
+				lit = "/"
+				break
 			}
+			// the sign of a number is a unary operator; "a-1" is a
+			// subtraction
+			tok = token.SUB
+			lit = "-"
+		case '+':
+			tok = token.ADD
+			lit = "+"
 		case '*':
 			tok = token.MUL
 			lit = "*"
+		case '%':
+			tok = token.REM
+			lit = "%"
 		case '(':
 			tok = token.LPAREN
 			lit = "("
@@ -129,37 +173,260 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 			tok = token.EQL
 			lit = "="
 		case '>':
-			// TODO peak next ch and check >= case.
 			tok = token.GTR
-			lit = ">"
+			if s.ch == '=' {
+				s.next()
+				tok = token.GEQ
+			}
+			lit = tok.String()
 		case '<':
-			// TODO peak next ch and check >= case.
-			tok = token.LSS
-			lit = "<"
+			switch s.ch {
+			case '=':
+				s.next()
+				tok = token.LEQ
+			case '>':
+				s.next()
+				tok = token.LSSGTR
+			default:
+				tok = token.LSS
+			}
+			lit = tok.String()
+		case '!':
+			if s.ch != '=' {
+				lit = s.illegalChar(offs, ch)
+				break
+			}
+			s.next()
+			tok = token.NEQ
+			lit = "!="
+		case '|':
+			if s.ch != '|' {
+				lit = s.illegalChar(offs, ch)
+				break
+			}
+			s.next()
+			tok = token.CONCAT
+			lit = "||"
+		case ':':
+			if s.ch != ':' {
+				lit = s.illegalChar(offs, ch)
+				break
+			}
+			s.next()
+			tok = token.CAST
+			lit = "::"
 		case '.':
 			tok = token.PERIOD
 			lit = "."
+		default:
+			lit = s.illegalChar(offs, ch)
 		}
 	}
 	return
 }
 
+// illegalChar reports the character ch at offs as illegal and returns
+// it as the literal of the ILLEGAL token.
+func (s *Scanner) illegalChar(offs int, ch rune) string {
+	// invalid encodings and byte order marks are reported by next
+	if ch != bom && ch != utf8.RuneError {
+		s.error(offs, fmt.Sprintf("illegal character %#U", ch))
+	}
+	return string(ch)
+}
+
+func (s *Scanner) error(offs int, msg string) {
+	if s.err != nil {
+		s.err(s.file.Position(s.file.Pos(offs)), msg)
+	}
+	s.ErrorCount++
+}
+
+// scanComment scans a -- line comment or a /* */ block comment starting
+// at offs. The first character of the comment marker has already been
+// consumed. A line comment doesn't include the terminating newline.
+func (s *Scanner) scanComment(offs int) string {
+	if s.ch == '-' {
+		// -- line comment
+		s.next()
+		for s.ch != '\n' && s.ch >= 0 {
+			s.next()
+		}
+		return strings.TrimSuffix(string(s.src[offs:s.offset]), "\r")
+	}
+
+	// /* block comment */
+	s.next()
+	for s.ch >= 0 {
+		ch := s.ch
+		s.next()
+		if ch == '*' && s.ch == '/' {
+			s.next()
+			return string(s.src[offs:s.offset])
+		}
+	}
+
+	s.error(offs, "comment not terminated")
+	return string(s.src[offs:s.offset])
+}
+
 func (s *Scanner) skipWhitespace() {
 	for s.ch == ' ' || s.ch == '\t' || s.ch == '\n' && !s.insertSemi || s.ch == '\r' {
 		s.next()
 	}
 }
 
-func (s *Scanner) scanString() string {
+// scanString scans a '...' string literal whose quote is at the current
+// character and which starts at offs. A quote is escaped by doubling it,
+// and any character is escaped by a backslash if backslash is set.
+func (s *Scanner) scanString(offs int, backslash bool) string {
+	s.next()
+	for {
+		if s.ch < 0 {
+			s.error(offs, "string literal not terminated")
+			break
+		}
+		ch := s.ch
+		s.next()
+		if ch == '\\' && backslash && s.ch >= 0 {
+			s.next()
+		} else if ch == '\'' {
+			if s.ch != '\'' {
+				break
+			}
+			s.next()
+		}
+	}
+	return string(s.src[offs:s.offset])
+}
+
+func isStringPrefix(ch rune) bool {
+	switch lower(ch) {
+	case 'e', 'n', 'x', 'b':
+		return true
+	}
+	return false
+}
+
+// scanPrefixedString scans an escape E'...', national N'...',
+// hexadecimal X'...' or bit B'...' string literal.
+func (s *Scanner) scanPrefixedString() string {
 	offs := s.offset
+	prefix := lower(s.ch)
 	s.next()
-	for s.ch != '\'' {
-		if s.ch == -1 {
-			panic("right single quote couldn't be found while scanning string")
+	lit := s.scanString(offs, prefix == 'e')
+
+	var valid func(rune) bool
+	switch prefix {
+	case 'x':
+		valid = isHex
+	case 'b':
+		valid = func(ch rune) bool { return ch == '0' || ch == '1' }
+	default:
+		return lit
+	}
+	for i, ch := range strings.TrimSuffix(lit[2:], "'") {
+		if !valid(ch) {
+			s.error(offs+2+i, fmt.Sprintf("illegal character %#U in %c'' literal", ch, lit[0]))
+			break
 		}
+	}
+	return lit
+}
+
+// dollarTag returns the $tag$ delimiter starting at the current
+// character, or "" if there is none.
+func (s *Scanner) dollarTag() string {
+	src := s.src[s.offset:]
+	for i, ch := range string(src[1:]) {
+		if ch == '$' {
+			return string(src[:i+2])
+		}
+		if !isLetter(ch) && !(i > 0 && isDigit(ch)) {
+			break
+		}
+	}
+	return ""
+}
+
+// scanDollarString scans a $tag$...$tag$ dollar quoted string literal.
+func (s *Scanner) scanDollarString() string {
+	offs := s.offset
+	tag := s.dollarTag()
+	end := len(s.src)
+	if i := strings.Index(string(s.src[offs+len(tag):]), tag); i >= 0 {
+		end = offs + len(tag) + i + len(tag)
+	} else {
+		s.error(offs, "dollar-quoted string not terminated")
+	}
+	for s.ch >= 0 && s.offset < end {
 		s.next()
 	}
+	return string(s.src[offs:s.offset])
+}
+
+func isParamPrefix(ch rune) bool {
+	return ch == '$' || ch == ':' || ch == '@'
+}
+
+// isParamNext reports whether the character following the parameter
+// prefix ch continues a parameter: a digit after $, and a letter or a
+// digit after : and @.
+func (s *Scanner) isParamNext(ch rune) bool {
+	next, _ := utf8.DecodeRune(s.src[s.rdOffset:])
+	if ch == '$' {
+		return isDigit(next)
+	}
+	return isLetter(next) || isDigit(next)
+}
+
+// scanParam scans a ? placeholder or a $1, :name or @name bind parameter.
+func (s *Scanner) scanParam() string {
+	offs := s.offset
+	ch := s.ch
+	s.next()
+	switch ch {
+	case '$':
+		for isDigit(s.ch) {
+			s.next()
+		}
+	case ':', '@':
+		for isLetter(s.ch) || isDigit(s.ch) {
+			s.next()
+		}
+	}
+	return string(s.src[offs:s.offset])
+}
+
+// closingQuote maps the opening quote of a quoted identifier to its
+// closing quote.
+var closingQuote = map[rune]rune{
+	'"': '"',
+	'`': '`',
+	'[': ']',
+}
+
+// scanQuotedIdent scans a "double quoted", `backquoted` or [bracketed]
+// identifier. A closing quote is escaped by doubling it. The literal
+// includes the quotes as written.
+func (s *Scanner) scanQuotedIdent() string {
+	offs := s.offset
+	quote := closingQuote[s.ch]
 	s.next()
+	for {
+		if s.ch < 0 {
+			s.error(offs, "quoted identifier not terminated")
+			break
+		}
+		ch := s.ch
+		s.next()
+		if ch == quote {
+			if s.ch != quote {
+				break
+			}
+			s.next()
+		}
+	}
 	return string(s.src[offs:s.offset])
 }
 
@@ -173,17 +440,27 @@ L:
 		switch {
 		case isDigit(s.ch):
 		case s.ch == '.':
-			if gotDot {
-				panic("got dot twice while scanning number.")
+			if gotDot || gotExp {
+				s.error(s.offset, "malformed number: unexpected '.'")
+				break L
 			}
 			gotDot = true
 			tok = token.REAL
 		case s.ch == 'e' || s.ch == 'E':
 			if gotExp {
-				panic("got exponential sign twice while scanning number.")
+				s.error(s.offset, "malformed number: unexpected exponent")
+				break L
 			}
 			gotExp = true
 			tok = token.REAL
+			s.next()
+			if s.ch == '+' || s.ch == '-' {
+				s.next()
+			}
+			if !isDigit(s.ch) {
+				s.error(offs, "exponent has no digits")
+				break L
+			}
 		default:
 			break L
 		}
@@ -211,6 +488,18 @@ func (s *Scanner) next() {
 			s.file.AddLine(s.offset)
 		}
 		r, w := rune(s.src[s.rdOffset]), 1
+		switch {
+		case r == 0:
+			s.error(s.offset, "illegal character NUL")
+		case r >= utf8.RuneSelf:
+			// not ASCII
+			r, w = utf8.DecodeRune(s.src[s.rdOffset:])
+			if r == utf8.RuneError && w == 1 {
+				s.error(s.offset, "illegal UTF-8 encoding")
+			} else if r == bom && s.offset > 0 {
+				s.error(s.offset, "illegal byte order mark")
+			}
+		}
 		s.rdOffset += w
 		s.ch = r
 	} else {
@@ -224,6 +513,21 @@ func (s *Scanner) next() {
 	}
 }
 
+// peek returns the byte following the current character, or 0 at the
+// end of the source.
+func (s *Scanner) peek() byte {
+	if s.rdOffset < len(s.src) {
+		return s.src[s.rdOffset]
+	}
+	return 0
+}
+
+func lower(ch rune) rune { return ('a' - 'A') | ch }
+
+func isHex(ch rune) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= lower(ch) && lower(ch) <= 'f'
+}
+
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
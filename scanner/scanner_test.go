@@ -39,9 +39,14 @@ func TestScan(t *testing.T) {
 			scanSet{tok: token.REAL, pos: 1, lit: "0.1"},
 			scanSet{tok: token.REAL, pos: 5, lit: "1.0E01"},
 		}},
-		testSet{given: []byte("+1 -1"), expect: []scanSet{
-			scanSet{tok: token.INT, pos: 1, lit: "+1"},
-			scanSet{tok: token.INT, pos: 4, lit: "-1"},
+		testSet{given: []byte("+1 -1 a-1"), expect: []scanSet{
+			scanSet{tok: token.ADD, pos: 1, lit: "+"},
+			scanSet{tok: token.INT, pos: 2, lit: "1"},
+			scanSet{tok: token.SUB, pos: 4, lit: "-"},
+			scanSet{tok: token.INT, pos: 5, lit: "1"},
+			scanSet{tok: token.IDENT, pos: 7, lit: "a"},
+			scanSet{tok: token.SUB, pos: 8, lit: "-"},
+			scanSet{tok: token.INT, pos: 9, lit: "1"},
 		}},
 		testSet{given: []byte("1 11"), expect: []scanSet{
 			scanSet{tok: token.INT, pos: 1, lit: "1"},
@@ -62,6 +67,29 @@ func TestScan(t *testing.T) {
 			scanSet{tok: token.GTR, pos: 1, lit: ">"},
 			scanSet{tok: token.EQL, pos: 3, lit: "="},
 		}},
+		testSet{given: []byte(">= <= <> != || :: / %"), expect: []scanSet{
+			scanSet{tok: token.GEQ, pos: 1, lit: ">="},
+			scanSet{tok: token.LEQ, pos: 4, lit: "<="},
+			scanSet{tok: token.LSSGTR, pos: 7, lit: "<>"},
+			scanSet{tok: token.NEQ, pos: 10, lit: "!="},
+			scanSet{tok: token.CONCAT, pos: 13, lit: "||"},
+			scanSet{tok: token.CAST, pos: 16, lit: "::"},
+			scanSet{tok: token.QUO, pos: 19, lit: "/"},
+			scanSet{tok: token.REM, pos: 21, lit: "%"},
+		}},
+		testSet{given: []byte("a::int<b"), expect: []scanSet{
+			scanSet{tok: token.IDENT, pos: 1, lit: "a"},
+			scanSet{tok: token.CAST, pos: 2, lit: "::"},
+			scanSet{tok: token.IDENT, pos: 4, lit: "int"},
+			scanSet{tok: token.LSS, pos: 7, lit: "<"},
+			scanSet{tok: token.IDENT, pos: 8, lit: "b"},
+		}},
+		testSet{given: []byte("\"Order Details\" `a``b` [x]]y] \"\"\"q\""), expect: []scanSet{
+			scanSet{tok: token.QUOTED_IDENT, pos: 1, lit: "\"Order Details\""},
+			scanSet{tok: token.QUOTED_IDENT, pos: 17, lit: "`a``b`"},
+			scanSet{tok: token.QUOTED_IDENT, pos: 24, lit: "[x]]y]"},
+			scanSet{tok: token.QUOTED_IDENT, pos: 31, lit: "\"\"\"q\""},
+		}},
 		testSet{given: []byte("tbl.col"), expect: []scanSet{
 			scanSet{tok: token.IDENT, pos: 1, lit: "tbl"},
 			scanSet{tok: token.PERIOD, pos: 4, lit: "."},
@@ -74,10 +102,41 @@ func TestScan(t *testing.T) {
 		testSet{given: []byte("'2015-11-11'"), expect: []scanSet{
 			scanSet{tok: token.STRING, pos: 1, lit: "'2015-11-11'"},
 		}},
+		testSet{given: []byte(`'it''s' E'a\'b\\' n'x' X'0aF' b'01' e`), expect: []scanSet{
+			scanSet{tok: token.STRING, pos: 1, lit: `'it''s'`},
+			scanSet{tok: token.STRING, pos: 9, lit: `E'a\'b\\'`},
+			scanSet{tok: token.STRING, pos: 19, lit: `n'x'`},
+			scanSet{tok: token.STRING, pos: 24, lit: `X'0aF'`},
+			scanSet{tok: token.STRING, pos: 31, lit: `b'01'`},
+			scanSet{tok: token.IDENT, pos: 37, lit: `e`},
+		}},
+		testSet{given: []byte("$$a'b$$ $body$\nselect $1;\n$body$"), expect: []scanSet{
+			scanSet{tok: token.STRING, pos: 1, lit: "$$a'b$$"},
+			scanSet{tok: token.STRING, pos: 9, lit: "$body$\nselect $1;\n$body$"},
+		}},
+		testSet{given: []byte("? $1 :name @p1 :2 a::b $x$1$x$"), expect: []scanSet{
+			scanSet{tok: token.PARAM, pos: 1, lit: "?"},
+			scanSet{tok: token.PARAM, pos: 3, lit: "$1"},
+			scanSet{tok: token.PARAM, pos: 6, lit: ":name"},
+			scanSet{tok: token.PARAM, pos: 12, lit: "@p1"},
+			scanSet{tok: token.PARAM, pos: 16, lit: ":2"},
+			scanSet{tok: token.IDENT, pos: 19, lit: "a"},
+			scanSet{tok: token.CAST, pos: 20, lit: "::"},
+			scanSet{tok: token.IDENT, pos: 22, lit: "b"},
+			scanSet{tok: token.STRING, pos: 24, lit: "$x$1$x$"},
+		}},
 		testSet{given: []byte(", ."), expect: []scanSet{
 			scanSet{tok: token.COMMA, pos: 1, lit: ","},
 			scanSet{tok: token.PERIOD, pos: 3, lit: "."},
 		}},
+		testSet{given: []byte("-- line\n1 /* block\n */ -1 --"), expect: []scanSet{
+			scanSet{tok: token.COMMENT, pos: 1, lit: "-- line"},
+			scanSet{tok: token.INT, pos: 9, lit: "1"},
+			scanSet{tok: token.COMMENT, pos: 11, lit: "/* block\n */"},
+			scanSet{tok: token.SUB, pos: 24, lit: "-"},
+			scanSet{tok: token.INT, pos: 25, lit: "1"},
+			scanSet{tok: token.COMMENT, pos: 27, lit: "--"},
+		}},
 	}
 
 	for ix, test := range ts {
@@ -97,6 +156,159 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanSkipComments(t *testing.T) {
+	src := []byte("select -- comment\n/* block */ a")
+	var s Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("test.sql", fset.Base(), len(src)), src, nil, 0)
+
+	expect := []scanSet{
+		scanSet{tok: token.SELECT, pos: 1, lit: "select"},
+		scanSet{tok: token.IDENT, pos: 31, lit: "a"},
+	}
+	actual := []scanSet{}
+	for {
+		var ss scanSet
+		ss.pos, ss.tok, ss.lit = s.Scan()
+		if ss.tok == token.EOF {
+			break
+		}
+		actual = append(actual, ss)
+	}
+	if err := isSameScanSetSlice(actual, expect); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	s := defaultInit([]byte("left /* c */ join 'x"))
+	if _, tok, _ := s.Scan(); tok != token.IDENT {
+		t.Fatalf("1st token is %s, expect IDENT.", tok)
+	}
+	if tok := s.Peek(); tok != token.JOIN {
+		t.Errorf("Peek returned %s, expect JOIN.", tok)
+	}
+	if _, tok, _ := s.Scan(); tok != token.COMMENT {
+		t.Errorf("Scan after Peek returned %s, expect COMMENT.", tok)
+	}
+	s.Scan()
+	if s.Peek(); s.ErrorCount != 0 {
+		t.Errorf("Peek must not report errors. ErrorCount: %d", s.ErrorCount)
+	}
+	if s.Scan(); s.ErrorCount != 1 {
+		t.Errorf("Scan must report the error. ErrorCount: %d", s.ErrorCount)
+	}
+}
+
+func TestScanUnterminatedComment(t *testing.T) {
+	src := []byte("a /* block")
+	var s Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("test.sql", fset.Base(), len(src)), src, nil, ScanComments)
+	for {
+		_, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+	}
+	if s.ErrorCount != 1 {
+		t.Errorf("ErrorCount is incorrect. actual: %d, expect: 1", s.ErrorCount)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		src    string
+		expect string
+	}{
+		{"'abc", "test.sql:1:1: string literal not terminated"},
+		{"1.2.3", "test.sql:1:4: malformed number: unexpected '.'"},
+		{"1e+", "test.sql:1:1: exponent has no digits"},
+		{"a\n  #", "test.sql:2:3: illegal character U+0023 '#'"},
+		{"a | b", "test.sql:1:3: illegal character U+007C '|'"},
+		{"a ! b", "test.sql:1:3: illegal character U+0021 '!'"},
+		{"a \"b", "test.sql:1:3: quoted identifier not terminated"},
+		{"[a]]", "test.sql:1:1: quoted identifier not terminated"},
+		{"'it''s", "test.sql:1:1: string literal not terminated"},
+		{"E'a\\'", "test.sql:1:1: string literal not terminated"},
+		{"X'0g'", "test.sql:1:4: illegal character U+0067 'g' in X'' literal"},
+		{"B'012'", "test.sql:1:5: illegal character U+0032 '2' in B'' literal"},
+		{"a $", "test.sql:1:3: illegal character U+0024 '$'"},
+		{"$a$ b $ab$", "test.sql:1:1: dollar-quoted string not terminated"},
+		{"名前 #", "test.sql:1:4: illegal character U+0023 '#'"},
+		{"a \xff", "test.sql:1:3: illegal UTF-8 encoding"},
+		{"a \ufeff", "test.sql:1:3: illegal byte order mark"},
+		{"\ufeffa #", "test.sql:1:4: illegal character U+0023 '#'"},
+	}
+	for _, test := range tests {
+		var list ErrorList
+		eh := func(pos token.Position, msg string) {
+			list.Add(pos, msg)
+		}
+		var s Scanner
+		fset := token.NewFileSet()
+		src := []byte(test.src)
+		s.Init(fset.AddFile("test.sql", fset.Base(), len(src)), src, eh, 0)
+		for {
+			_, tok, _ := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+		}
+		if len(list) != 1 || list[0].Error() != test.expect {
+			t.Errorf("errors for %q are incorrect. actual: %v, expect: %s", test.src, list, test.expect)
+		}
+	}
+}
+
+func TestScanUnicode(t *testing.T) {
+	src := []byte("select 名前, café from テーブル")
+	expect := []struct {
+		tok token.Token
+		lit string
+	}{
+		{token.SELECT, "select"},
+		{token.IDENT, "名前"},
+		{token.COMMA, ","},
+		{token.IDENT, "café"},
+		{token.FROM, "from"},
+		{token.IDENT, "テーブル"},
+		{token.EOF, ""},
+	}
+	var s Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("test.sql", fset.Base(), len(src)), src, nil, 0)
+	for _, e := range expect {
+		_, tok, lit := s.Scan()
+		if tok != e.tok || lit != e.lit {
+			t.Errorf("Scan returned %s %q, expect %s %q", tok, lit, e.tok, e.lit)
+		}
+	}
+	if s.ErrorCount != 0 {
+		t.Errorf("unexpected %d errors", s.ErrorCount)
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	list.Add(token.Position{Line: 2, Column: 5}, "b")
+	list.Add(token.Position{Line: 1, Column: 3}, "a")
+	list.Add(token.Position{Line: 2, Column: 1}, "c")
+	list.RemoveMultiples()
+
+	if expect := "1:3: a (and 1 more errors)"; list.Error() != expect {
+		t.Errorf("ErrorList message is incorrect. actual: %s, expect: %s", list.Error(), expect)
+	}
+	var buf strings.Builder
+	PrintError(&buf, list)
+	if expect := "1:3: a\n2:1: c\n"; buf.String() != expect {
+		t.Errorf("PrintError output is incorrect. actual: %q, expect: %q", buf.String(), expect)
+	}
+	if ErrorList(nil).Err() != nil {
+		t.Error("Err of empty ErrorList must be nil.")
+	}
+}
+
 func isSameScanSetSlice(actual, expect []scanSet) error {
 	if len(actual) != len(expect) {
 		return fmt.Errorf("# of scanned is different with expected. actual: %v, expected: %v", actual, expect)
@@ -1,62 +1,283 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/Neetless/sqlfmt/parser"
 	printer "github.com/Neetless/sqlfmt/printer"
+	"github.com/Neetless/sqlfmt/scanner"
 	"github.com/Neetless/sqlfmt/token"
 )
 
 const (
 	exitSuccess int = iota
 	exitError
+	exitNeedsFormat // -check found files whose formatting differs
 )
 
+// stdinFilename is the pseudo filename used for source read from stdin.
+const stdinFilename = "<standard input>"
+
 type formatter struct {
 	fset *token.FileSet
 	out  io.Writer
+
+	write bool // write result to (source) file instead of out
+	list  bool // list files whose formatting differs from sqlfmt's
+	diff  bool // display diffs instead of rewriting files
+	check bool // report files whose formatting differs and fail
+	safe  bool // verify the formatted source parses to the same tree
+
+	in            io.Reader // source used when no file arguments are given
+	stdinFilepath string    // filename reported for source read from in
+
+	exts     []string // file extensions formatted when walking directories
+	excludes []string // glob patterns of paths skipped when walking directories
+
+	exitCode int
 }
 
 func main() {
 	var outputFilename string
+	var fmter formatter
 	flag.StringVar(&outputFilename, "o", "", "-o=FILE\twrite documents to FILE")
+	flag.BoolVar(&fmter.write, "w", false, "write result to (source) file instead of stdout")
+	flag.BoolVar(&fmter.list, "l", false, "list files whose formatting differs from sqlfmt's")
+	flag.BoolVar(&fmter.diff, "d", false, "display diffs instead of rewriting files")
+	flag.BoolVar(&fmter.check, "check", false, "report files whose formatting differs and exit with a non-zero status")
+	flag.BoolVar(&fmter.safe, "safe", false, "check that the formatted source parses to the same tree and fail if not")
+	flag.StringVar(&fmter.stdinFilepath, "stdin-filepath", "", "-stdin-filepath=PATH\tuse PATH as the filename of source read from stdin")
+	exts := flag.String("ext", strings.Join(defaultExts, ","), "-ext=EXTS\tcomma separated file extensions formatted in directories")
+	flag.Var((*stringList)(&fmter.excludes), "exclude", "-exclude=GLOB\tskip paths matching GLOB in directories (repeatable)")
 	flag.Parse()
 
-	if flag.NArg() < 1 {
-		log.Fatal("requires input source.")
-	}
+	fmter.exts = splitExts(*exts)
 
-	var fmter formatter
+	if flag.NArg() < 1 && fmter.write {
+		log.Fatal("cannot use -w with standard input.")
+	}
+	if fmter.check && fmter.write {
+		log.Fatal("cannot use -check with -w.")
+	}
 
+	var outFile *os.File
 	if outputFilename != "" {
+		if fmter.write {
+			log.Fatal("cannot use -o with -w.")
+		}
+		file, err := os.Create(outputFilename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outFile = file
+		fmter.out = file
 	} else {
 		fmter.out = os.Stdout
 	}
 
 	fmter.fset = token.NewFileSet()
+	fmter.in = os.Stdin
 
-	code := sqlfmtMain(fmter)
+	code := sqlfmtMain(fmter, flag.Args())
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			log.Println(err)
+			code = exitError
+		}
+	}
 	os.Exit(code)
 }
 
-func sqlfmtMain(fmter formatter) int {
+func sqlfmtMain(fmter formatter, args []string) int {
+	if len(args) == 0 {
+		filename := fmter.stdinFilepath
+		if filename == "" {
+			filename = stdinFilename
+		}
+		if err := fmter.processFile(filename, fmter.in); err != nil {
+			fmter.report(err)
+		}
+		return fmter.exitCode
+	}
+
+	for _, arg := range args {
+		switch fi, err := os.Stat(arg); {
+		case err != nil:
+			fmter.report(err)
+		case fi.IsDir():
+			fmter.walkDir(arg)
+		default:
+			if err := fmter.processFile(arg, nil); err != nil {
+				fmter.report(err)
+			}
+		}
+	}
+
+	return fmter.exitCode
+}
+
+// report prints err and remembers that the command failed.
+func (f *formatter) report(err error) {
+	scanner.PrintError(os.Stderr, err)
+	f.exitCode = exitError
+}
 
-	for _, arg := range flag.Args() {
-		stmt, err := parser.ParseFile(fmter.fset, arg, nil)
+// needsFormat remembers that a file's formatting differs, unless an
+// error has been reported already.
+func (f *formatter) needsFormat() {
+	if f.exitCode == exitSuccess {
+		f.exitCode = exitNeedsFormat
+	}
+}
+
+// processFile formats a single file. If in is nil, the source is read
+// from filename.
+func (f *formatter) processFile(filename string, in io.Reader) error {
+	var perm os.FileMode = 0644
+	if in == nil {
+		file, err := os.Open(filename)
 		if err != nil {
-			log.Println(err)
-			return exitError
+			return err
 		}
+		defer file.Close()
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		perm = fi.Mode().Perm()
+		in = file
+	}
 
-		if err := printer.Fprint(fmter.out, fmter.fset, stmt); err != nil {
-			log.Println(err)
-			return exitError
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := parser.ParseFile(f.fset, filename, src)
+	if err != nil {
+		return err
+	}
+
+	cfg := printer.NewConfig()
+	cfg.Safe = f.safe
+
+	var buf bytes.Buffer
+	if err := cfg.Fprint(&buf, f.fset, stmt); err != nil {
+		return err
+	}
+	res := buf.Bytes()
+
+	if !bytes.Equal(src, res) {
+		if f.list {
+			fmt.Fprintln(f.out, filename)
+		}
+		if f.check {
+			fmt.Fprintf(f.out, "would reformat %s\n", filename)
+			f.needsFormat()
+		}
+		if f.write {
+			if err := writeFile(filename, res, perm); err != nil {
+				return err
+			}
 		}
+		if f.diff {
+			data, err := diff(src, res, filename)
+			if err != nil {
+				return fmt.Errorf("computing diff: %s", err)
+			}
+			fmt.Fprintf(f.out, "diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+			f.out.Write(data)
+		}
+	}
+
+	if !f.list && !f.write && !f.diff && !f.check {
+		_, err = f.out.Write(res)
+	}
+
+	return err
+}
+
+// writeFile replaces filename with data. The data is written to a
+// temporary file in the same directory first and then renamed over
+// filename, so a failure never leaves a half written file behind.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, base+".sqlfmt")
+	if err != nil {
+		return err
 	}
+	tmpname := tmp.Name()
 
-	return exitSuccess
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmpname, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpname, filename)
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return nil
+}
+
+func writeTempFile(dir, prefix string, data []byte) (string, error) {
+	file, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// diff returns a unified diff of b1 and b2 labelled with filename.
+// It relies on the diff command being available in PATH.
+func diff(b1, b2 []byte, filename string) (data []byte, err error) {
+	f1, err := writeTempFile("", "sqlfmt", b1)
+	if err != nil {
+		return
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTempFile("", "sqlfmt", b2)
+	if err != nil {
+		return
+	}
+	defer os.Remove(f2)
+
+	name := filepath.ToSlash(filename)
+	data, err = exec.Command("diff", "-u", "-L", name+".orig", "-L", name, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			err = nil
+		}
+	}
+	return
 }
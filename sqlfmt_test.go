@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Neetless/sqlfmt/token"
+)
+
+const (
+	unformattedSQL = "select * from table1;"
+	formattedSQL   = "SELECT\n    *\nFROM\n    table1\n;\n"
+)
+
+func writeTestFile(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "sqlfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "test.sql")
+	if err := ioutil.WriteFile(filename, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestProcessFileWrite(t *testing.T) {
+	filename := writeTestFile(t, unformattedSQL)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, write: true}
+	if code := sqlfmtMain(fmter, []string{filename}); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != formattedSQL {
+		t.Errorf("file is not rewritten. expect:\n%s\nactual:\n%s", formattedSQL, got)
+	}
+	if out.Len() != 0 {
+		t.Errorf("-w must not write to output. actual: %q", out.String())
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode is not preserved. actual: %v", fi.Mode().Perm())
+	}
+}
+
+func TestProcessFileList(t *testing.T) {
+	unformatted := writeTestFile(t, unformattedSQL)
+	defer os.RemoveAll(filepath.Dir(unformatted))
+	formatted := writeTestFile(t, formattedSQL)
+	defer os.RemoveAll(filepath.Dir(formatted))
+
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, list: true}
+	if code := sqlfmtMain(fmter, []string{unformatted, formatted}); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+
+	if expect := unformatted + "\n"; out.String() != expect {
+		t.Errorf("-l output is incorrect. expect: %q, actual: %q", expect, out.String())
+	}
+}
+
+func TestProcessFileDiff(t *testing.T) {
+	filename := writeTestFile(t, unformattedSQL)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, diff: true}
+	if code := sqlfmtMain(fmter, []string{filename}); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+
+	for _, line := range []string{"-" + unformattedSQL, "+SELECT", "+    table1"} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("-d output does not contain %q. actual:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestProcessFileError(t *testing.T) {
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out}
+	if code := sqlfmtMain(fmter, []string{"testdata/not_exist.sql"}); code != exitError {
+		t.Errorf("sqlfmtMain returned %d, expect %d.", code, exitError)
+	}
+}
+
+func TestProcessStdin(t *testing.T) {
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, in: strings.NewReader(unformattedSQL)}
+	if code := sqlfmtMain(fmter, nil); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+	if out.String() != formattedSQL {
+		t.Errorf("stdin is not formatted. expect:\n%s\nactual:\n%s", formattedSQL, out.String())
+	}
+
+	out.Reset()
+	fmter = formatter{fset: token.NewFileSet(), out: &out, list: true, in: strings.NewReader(unformattedSQL)}
+	sqlfmtMain(fmter, nil)
+	if expect := stdinFilename + "\n"; out.String() != expect {
+		t.Errorf("-l output is incorrect. expect: %q, actual: %q", expect, out.String())
+	}
+
+	out.Reset()
+	fmter.in = strings.NewReader(unformattedSQL)
+	fmter.stdinFilepath = "queries/report.sql"
+	sqlfmtMain(fmter, nil)
+	if expect := "queries/report.sql\n"; out.String() != expect {
+		t.Errorf("-l output is incorrect. expect: %q, actual: %q", expect, out.String())
+	}
+}
+
+func TestProcessFileCheck(t *testing.T) {
+	unformatted := writeTestFile(t, unformattedSQL)
+	defer os.RemoveAll(filepath.Dir(unformatted))
+	formatted := writeTestFile(t, formattedSQL)
+	defer os.RemoveAll(filepath.Dir(formatted))
+
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, check: true}
+	if code := sqlfmtMain(fmter, []string{formatted}); code != exitSuccess {
+		t.Errorf("sqlfmtMain returned %d for formatted file, expect %d.", code, exitSuccess)
+	}
+	if out.Len() != 0 {
+		t.Errorf("-check output must be empty for formatted file. actual: %q", out.String())
+	}
+
+	if code := sqlfmtMain(fmter, []string{unformatted, formatted}); code != exitNeedsFormat {
+		t.Errorf("sqlfmtMain returned %d for unformatted file, expect %d.", code, exitNeedsFormat)
+	}
+	if expect := "would reformat " + unformatted + "\n"; out.String() != expect {
+		t.Errorf("-check output is incorrect. expect: %q, actual: %q", expect, out.String())
+	}
+
+	if code := sqlfmtMain(fmter, []string{unformatted, "testdata/not_exist.sql"}); code != exitError {
+		t.Errorf("sqlfmtMain returned %d with an error, expect %d.", code, exitError)
+	}
+
+	src, err := ioutil.ReadFile(unformatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != unformattedSQL {
+		t.Errorf("-check must not modify files. actual:\n%s", src)
+	}
+}
+
+func TestProcessFileSafe(t *testing.T) {
+	var out bytes.Buffer
+	fmter := formatter{fset: token.NewFileSet(), out: &out, safe: true, in: strings.NewReader(unformattedSQL)}
+	if code := sqlfmtMain(fmter, nil); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+	if out.String() != formattedSQL {
+		t.Errorf("-safe output is incorrect. expect:\n%s\nactual:\n%s", formattedSQL, out.String())
+	}
+}
@@ -1,8 +1,10 @@
 package token
 
 import (
+	"fmt"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 // Token is the set of lexical tokens of the SQL language.
@@ -13,10 +15,12 @@ const (
 	ILLEGAL Token = iota
 
 	IDENT
+	QUOTED_IDENT // "name", `name` or [name]
 	INT
 	REAL
 	ASTA
 	STRING
+	PARAM // ?, $1, :name or @name
 
 	keywordBeg
 	SELECT
@@ -36,8 +40,46 @@ const (
 	OR  // or
 	IS
 	NULL
+	JOIN
+	INNER
+	OUTER
+	CROSS
+	NATURAL
+	ON
+	USING
+	EXISTS
+	IN
+	WITH
+	UNION
+	INTERSECT
+	EXCEPT
+	DISTINCT
+	HAVING
+	ASC
+	DESC
+	COLLATE
 	keywordEnd
 
+	// Contextual keywords are scanned as identifiers. The parser
+	// recognizes them only where they have a meaning, so that they can
+	// still name columns and functions.
+	contextualBeg
+	LEFT  // LEFT [OUTER] JOIN
+	RIGHT // RIGHT [OUTER] JOIN
+	FULL  // FULL [OUTER] JOIN
+	ANY   // x op ANY (subquery)
+	ALL   // x op ALL (subquery), UNION ALL
+	SOME  // x op SOME (subquery)
+
+	RECURSIVE    // WITH RECURSIVE
+	MATERIALIZED // AS [NOT] MATERIALIZED
+
+	LIMIT  // LIMIT count
+	OFFSET // OFFSET start [ROW | ROWS]
+	FETCH  // FETCH {FIRST | NEXT} [count] {ROW | ROWS} {ONLY | WITH TIES}
+	TOP    // SELECT TOP count
+	contextualEnd
+
 	operatorBeg
 	ADD // +
 	SUB // -
@@ -45,12 +87,15 @@ const (
 	QUO // /
 	REM // %
 
-	EQL // =
-	NEQ // !=
-	GTR // >
-	GEQ // >=
-	LSS // <
-	LEQ // <=
+	EQL    // =
+	NEQ    // !=
+	LSSGTR // <>
+	GTR    // >
+	GEQ    // >=
+	LSS    // <
+	LEQ    // <=
+	CONCAT // ||
+	CAST   // ::
 	LPAREN
 	RPAREN
 	SEMICOLON
@@ -68,10 +113,12 @@ var tokens = [...]string{
 
 	COMMENT: "COMMENT",
 
-	IDENT:  "IDENT",
-	INT:    "INT",
-	REAL:   "REAL",
-	STRING: "STRING",
+	IDENT:        "IDENT",
+	QUOTED_IDENT: "QUOTED_IDENT",
+	INT:          "INT",
+	REAL:         "REAL",
+	STRING:       "STRING",
+	PARAM:        "PARAM",
 
 	SELECT: "SELECT",
 	FROM:   "FROM",
@@ -85,19 +132,59 @@ var tokens = [...]string{
 	THEN:   "THEN",
 	ELSE:   "ELSE",
 	END:    "END",
+	NOT:    "NOT",
 	AND:    "AND",
 	OR:     "OR",
 	IS:     "IS",
 	NULL:   "NULL",
 
+	JOIN:    "JOIN",
+	INNER:   "INNER",
+	LEFT:    "LEFT",
+	RIGHT:   "RIGHT",
+	FULL:    "FULL",
+	OUTER:   "OUTER",
+	CROSS:   "CROSS",
+	NATURAL: "NATURAL",
+	ON:      "ON",
+	USING:   "USING",
+	EXISTS:  "EXISTS",
+	IN:      "IN",
+	ANY:     "ANY",
+	ALL:     "ALL",
+	SOME:    "SOME",
+
+	WITH:         "WITH",
+	RECURSIVE:    "RECURSIVE",
+	MATERIALIZED: "MATERIALIZED",
+	UNION:        "UNION",
+	INTERSECT:    "INTERSECT",
+	EXCEPT:       "EXCEPT",
+	DISTINCT:     "DISTINCT",
+	HAVING:       "HAVING",
+	LIMIT:        "LIMIT",
+	OFFSET:       "OFFSET",
+	FETCH:        "FETCH",
+	TOP:          "TOP",
+	ASC:          "ASC",
+	DESC:         "DESC",
+	COLLATE:      "COLLATE",
+
 	ASTA:      "*",
 	ADD:       "+",
 	SUB:       "-",
 	MUL:       "*",
 	QUO:       "/",
+	REM:       "%",
 	EQL:       "=",
+	NEQ:       "!=",
+	LSSGTR:    "<>",
 	GTR:       ">",
+	GEQ:       ">=",
 	LSS:       "<",
+	LEQ:       "<=",
+	CONCAT:    "||",
+	CAST:      "::",
 	LPAREN:    "(",
 	RPAREN:    ")",
 	SEMICOLON: ";",
@@ -112,6 +199,34 @@ func init() {
 	for i := keywordBeg + 1; i < keywordEnd; i++ {
 		keywords[tokens[i]] = i
 	}
+	for i := contextualBeg + 1; i < contextualEnd; i++ {
+		notAlias[tokens[i]] = true
+	}
+}
+
+// notAlias is the set of words which are scanned as identifiers, but
+// which can't be an alias written without AS: the contextual keywords,
+// some of which start a join or a clause after an alias, and the reserved
+// words listed here. Keywords are tokens of their own and never taken
+// for one.
+var notAlias = map[string]bool{
+	"FOR":       true,
+	"INTO":      true,
+	"WINDOW":    true,
+	"QUALIFY":   true,
+	"RETURNING": true,
+}
+
+// CanOmitAs reports whether the alias name, written after AS, would
+// also be parsed as an alias without AS.
+func CanOmitAs(name string) bool {
+	return !notAlias[strings.ToUpper(name)]
+}
+
+// IsKeyword reports whether t is a keyword, including the contextual
+// keywords which are scanned as identifiers.
+func (t Token) IsKeyword() bool {
+	return keywordBeg < t && t < keywordEnd || contextualBeg < t && t < contextualEnd
 }
 
 func (t Token) String() string {
@@ -136,6 +251,10 @@ func Lookup(ident string) Token {
 //
 type Pos int
 
+// NoPos is the zero value for Pos; there is no file and line information
+// associated with it.
+const NoPos Pos = 0
+
 // File is a handle for a file belonging to a FileSet.
 type File struct {
 	set  *FileSet
@@ -145,6 +264,7 @@ type File struct {
 
 	lines []int
 	infos []lineInfo
+	src   []byte // source of the file, if known; used to count columns in runes
 }
 
 // Name returns the file name of file f as registered  with AddFile.
@@ -168,6 +288,19 @@ func (f *File) Pos(offset int) Pos {
 	return Pos(f.base + offset)
 }
 
+// SetSource records the source of file f, which must be of the file's
+// size. With a source, columns of positions are counted in characters
+// (runes) instead of bytes.
+//
+func (f *File) SetSource(src []byte) {
+	if len(src) != f.size {
+		panic("token.File.SetSource: source size does not match file size")
+	}
+	f.set.mutex.Lock()
+	f.src = src
+	f.set.mutex.Unlock()
+}
+
 // AddLine adds the line offset for a new line.
 // The line offset must be larger than the offset for the previous line
 // and smaller than the file size; otherwise the line offset is igonred.
@@ -180,6 +313,126 @@ func (f *File) AddLine(offset int) {
 	f.set.mutex.Unlock()
 }
 
+// LineCount returns the number of lines in file f.
+func (f *File) LineCount() int {
+	f.set.mutex.RLock()
+	n := len(f.lines)
+	f.set.mutex.RUnlock()
+	return n
+}
+
+// LineStart returns the Pos value of the start of the specified line.
+// The line number must be 1 <= line <= f.LineCount().
+//
+func (f *File) LineStart(line int) Pos {
+	if line < 1 {
+		panic("illegal line number (line numbering starts at 1)")
+	}
+	f.set.mutex.RLock()
+	defer f.set.mutex.RUnlock()
+	if line > len(f.lines) {
+		panic("illegal line number")
+	}
+	return Pos(f.base + f.lines[line-1])
+}
+
+// AddLineInfo adds alternative file and line number information for
+// a given file offset. The offset must be larger than the offset for
+// the previously added alternative line info and smaller than the
+// file size; otherwise the information is ignored.
+//
+// AddLineInfo is typically used to register alternative position
+// information for sql embedded in other sources, so that positions
+// are reported relative to the original source.
+//
+func (f *File) AddLineInfo(offset int, filename string, line int) {
+	f.set.mutex.Lock()
+	if i := len(f.infos); (i == 0 || f.infos[i-1].Offset < offset) && offset < f.size {
+		f.infos = append(f.infos, lineInfo{offset, filename, line})
+	}
+	f.set.mutex.Unlock()
+}
+
+// Offset returns the offset for the given file position p;
+// p must be a valid Pos value in that file.
+// f.Offset(f.Pos(offset)) == offset.
+//
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic("illegal Pos value")
+	}
+	return int(p) - f.base
+}
+
+// Line returns the line number for the given file position p;
+// p must be a Pos value in that file or NoPos.
+//
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Position returns the Position value for the given file position p;
+// p must be a Pos value in that file or NoPos.
+//
+func (f *File) Position(p Pos) (pos Position) {
+	if p != NoPos {
+		pos.Offset = f.Offset(p)
+		pos.Filename, pos.Line, pos.Column = f.unpack(pos.Offset)
+	}
+	return
+}
+
+func (f *File) unpack(offset int) (filename string, line, column int) {
+	f.set.mutex.RLock()
+	defer f.set.mutex.RUnlock()
+	filename = f.name
+	if i := searchInts(f.lines, offset); i >= 0 {
+		line, column = i+1, offset-f.lines[i]+1
+		if f.src != nil && offset <= len(f.src) {
+			column = utf8.RuneCount(f.src[f.lines[i]:offset]) + 1
+		}
+	}
+	if len(f.infos) > 0 {
+		// almost no files have extra line infos
+		if i := searchLineInfos(f.infos, offset); i >= 0 {
+			alt := &f.infos[i]
+			filename = alt.Filename
+			if i := searchInts(f.lines, alt.Offset); i >= 0 {
+				line += alt.Line - i - 1
+			}
+		}
+	}
+	return
+}
+
+func searchLineInfos(a []lineInfo, x int) int {
+	i, j := 0, len(a)
+	for i < j {
+		h := i + (j-i)/2
+		if a[h].Offset <= x {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i - 1
+}
+
+// searchInts returns the index of the last element of a which is <= x.
+// a must be sorted in increasing order.
+func searchInts(a []int, x int) int {
+	i, j := 0, len(a)
+	for i < j {
+		h := i + (j-i)/2
+		if a[h] <= x {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i - 1
+}
+
 type lineInfo struct {
 	Offset   int
 	Filename string
@@ -223,7 +476,7 @@ func (s *FileSet) AddFile(filename string, base, size int) *File {
 		panic("illegal base or size")
 	}
 
-	f := &File{s, filename, base, size, []int{0}, nil}
+	f := &File{s, filename, base, size, []int{0}, nil, nil}
 	base += size + 1
 	if base < 0 {
 		panic("token.Pos offset overflow (> 2G of source code in file set)")
@@ -234,12 +487,71 @@ func (s *FileSet) AddFile(filename string, base, size int) *File {
 	return f
 }
 
+// File returns the file that contains the position p.
+// If no such file is found, the result is nil.
+//
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if f := s.last; f != nil && f.base <= int(p) && int(p) <= f.base+f.size {
+		return f
+	}
+	for _, f := range s.files {
+		if f.base <= int(p) && int(p) <= f.base+f.size {
+			return f
+		}
+	}
+	return nil
+}
+
+// Position returns the Position value for the given file position p.
+// If p is out of bounds of any file in the set, the result is the zero
+// Position.
+//
+func (s *FileSet) Position(p Pos) (pos Position) {
+	if f := s.File(p); f != nil {
+		pos = f.Position(p)
+	}
+	return
+}
+
 // Position describes an arbitrary source position
 // including the file, line, and column location.
 // A Position is valid if the line number is > 0.
 type Position struct {
-	Line   int
-	Column int
+	Filename string // filename, if any
+	Offset   int    // offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (rune count if the source is set, else byte count)
+}
+
+// IsValid reports whether the position is valid.
+func (pos *Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns a string in one of several forms:
+//
+//	file:line:column    valid position with file name
+//	line:column         valid position without file name
+//	file                invalid position with file name
+//	-                   invalid position without file name
+//
+func (pos Position) String() string {
+	s := pos.Filename
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 // A set of constants for precedence-based expression parsing.
@@ -250,8 +562,11 @@ type Position struct {
 //
 const (
 	LowestPrec  = 0 // non-operators
-	UnaryPrec   = 6
-	HighestPrec = 7
+	NotPrec     = 3 // NOT binds looser than IS NULL but tighter than AND
+	IsNullPrec  = 4 // IS NULL binds looser than comparisons
+	InPrec      = 6 // IN binds tighter than comparisons but looser than arithmetic
+	UnaryPrec   = 10
+	HighestPrec = 12
 )
 
 // Precedence returns the operator precedence of the binary
@@ -264,12 +579,17 @@ func (t Token) Precedence() int {
 		return 1
 	case AND:
 		return 2
-	case EQL, NEQ, LSS, LEQ, GTR, GEQ:
-		return 3
+	case EQL, NEQ, LSSGTR, LSS, LEQ, GTR, GEQ:
+		return 5
+	case CONCAT:
+		return 7
 	case ADD, SUB:
-		return 4
+		return 8
 	case MUL, QUO, REM:
-		return 5
+		return 9
+	case CAST:
+		// a cast binds tighter than a unary operator: -a::int is -(a::int)
+		return 11
 	}
 	return LowestPrec
 }
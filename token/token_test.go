@@ -26,6 +26,70 @@ func TestFileSetAddFile(t *testing.T) {
 	}
 }
 
+func TestFileSetPosition(t *testing.T) {
+	fs := NewFileSet()
+	src := "select a,\n  b\nfrom t;"
+	f1 := fs.AddFile("one.sql", fs.Base(), 10)
+	f2 := fs.AddFile("two.sql", fs.Base(), len(src))
+	for offset, ch := range src {
+		if ch == '\n' {
+			f2.AddLine(offset + 1)
+		}
+	}
+
+	if f := fs.File(f2.Pos(3)); f != f2 {
+		t.Errorf("FileSet.File returned %v, expect two.sql.", f)
+	}
+	if f := fs.File(f1.Pos(3)); f != f1 {
+		t.Errorf("FileSet.File returned %v, expect one.sql.", f)
+	}
+	if f := fs.File(NoPos); f != nil {
+		t.Errorf("FileSet.File(NoPos) returned %v, expect nil.", f)
+	}
+
+	tests := []struct {
+		offset int
+		expect string
+	}{
+		{0, "two.sql:1:1"},
+		{7, "two.sql:1:8"},
+		{12, "two.sql:2:3"},
+		{14, "two.sql:3:1"},
+		{len(src), "two.sql:3:8"},
+	}
+	for _, test := range tests {
+		p := f2.Pos(test.offset)
+		pos := fs.Position(p)
+		if pos.String() != test.expect {
+			t.Errorf("Position of offset %d is incorrect. actual: %s, expect: %s", test.offset, pos, test.expect)
+		}
+		if pos.Offset != test.offset || f2.Offset(p) != test.offset {
+			t.Errorf("Offset is incorrect. actual: %d, expect: %d", pos.Offset, test.offset)
+		}
+		if f2.Line(p) != pos.Line {
+			t.Errorf("Line of offset %d is incorrect. actual: %d, expect: %d", test.offset, f2.Line(p), pos.Line)
+		}
+	}
+
+	if f2.LineCount() != 3 {
+		t.Errorf("LineCount is incorrect. actual: %d, expect: 3", f2.LineCount())
+	}
+	if p := f2.LineStart(2); f2.Offset(p) != 10 {
+		t.Errorf("LineStart(2) is incorrect. actual offset: %d, expect: 10", f2.Offset(p))
+	}
+	assertPanic(t, func() { f2.LineStart(4) }, "LineStart did not panic by line: 4")
+
+	f2.AddLineInfo(10, "orig.sql", 20)
+	if pos := f2.Position(f2.Pos(14)).String(); pos != "orig.sql:21:1" {
+		t.Errorf("Position with line info is incorrect. actual: %s, expect: orig.sql:21:1", pos)
+	}
+
+	var invalid Position
+	if invalid.String() != "-" {
+		t.Errorf("invalid Position string is incorrect. actual: %s", invalid.String())
+	}
+}
+
 func assertPanic(t *testing.T, f func(), errState string) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -34,3 +98,11 @@ func assertPanic(t *testing.T, f func(), errState string) {
 	}()
 	f()
 }
+
+func TestCanOmitAs(t *testing.T) {
+	for name, expect := range map[string]bool{"uid": true, `"for"`: true, `"left"`: true, "for": false, "Returning": false, "left": false, "Top": false} {
+		if actual := CanOmitAs(name); actual != expect {
+			t.Errorf("CanOmitAs(%q) = %v, expect: %v", name, actual, expect)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFilename is the name of the file listing glob patterns of paths
+// skipped when walking the directory containing it.
+const ignoreFilename = ".sqlfmtignore"
+
+// defaultExts is the set of file extensions formatted when walking directories.
+var defaultExts = []string{".sql"}
+
+// skipDirs is the set of vendored or generated directory names which are
+// never descended into.
+var skipDirs = map[string]bool{
+	"vendor":       true,
+	"node_modules": true,
+	"generated":    true,
+}
+
+// stringList is a flag.Value collecting every occurrence of a flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends value to the list.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitExts parses a comma separated extension list. A leading dot is
+// added to each extension if it's omitted.
+func splitExts(s string) []string {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// walkDir formats every file under root whose extension is one of f.exts.
+// Hidden, vendored and generated directories, and paths matching
+// f.excludes or a pattern in root's .sqlfmtignore are skipped.
+func (f *formatter) walkDir(root string) {
+	patterns, err := readIgnoreFile(filepath.Join(root, ignoreFilename))
+	if err != nil {
+		f.report(err)
+		return
+	}
+	patterns = append(patterns, f.excludes...)
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			f.report(err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			f.report(err)
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (skipDir(info.Name()) || excluded(rel, patterns)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.isSQLFile(info) || excluded(rel, patterns) {
+			return nil
+		}
+		if err := f.processFile(path, nil); err != nil {
+			f.report(err)
+		}
+		return nil
+	})
+}
+
+func (f *formatter) isSQLFile(info os.FileInfo) bool {
+	name := info.Name()
+	if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") {
+		return false
+	}
+	exts := f.exts
+	if exts == nil {
+		exts = defaultExts
+	}
+	for _, ext := range exts {
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || skipDirs[name]
+}
+
+// excluded reports whether the slash separated form of rel, or its base
+// name, matches one of patterns. A pattern with a trailing slash is
+// treated as the pattern without it.
+func excluded(rel string, patterns []string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// readIgnoreFile reads glob patterns from filename, one per line. Blank
+// lines and lines starting with # are ignored. A missing file is not an
+// error.
+func readIgnoreFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, sc.Err()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Neetless/sqlfmt/token"
+)
+
+func TestWalkDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "sqlfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"a.sql":              unformattedSQL,
+		"b.txt":              unformattedSQL,
+		"c.ddl":              unformattedSQL,
+		"queries/d.SQL":      unformattedSQL,
+		"queries/e.sql":      formattedSQL,
+		"vendor/f.sql":       unformattedSQL,
+		".git/g.sql":         unformattedSQL,
+		"reports/h.sql":      unformattedSQL,
+		"migrations/i.sql":   unformattedSQL,
+		"migrations/old.sql": unformattedSQL,
+		ignoreFilename:       "# comment\n\nreports/\n",
+	}
+	for name, src := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmter := formatter{
+		fset:     token.NewFileSet(),
+		out:      &out,
+		list:     true,
+		exts:     splitExts("sql, .ddl"),
+		excludes: []string{"migrations/old*"},
+	}
+	if code := sqlfmtMain(fmter, []string{root}); code != exitSuccess {
+		t.Fatalf("sqlfmtMain returned %d, expect %d.", code, exitSuccess)
+	}
+
+	var actual []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		rel, err := filepath.Rel(root, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, filepath.ToSlash(rel))
+	}
+	expect := []string{"a.sql", "c.ddl", "migrations/i.sql", "queries/d.SQL"}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("walked files are incorrect. expect: %v, actual: %v", expect, actual)
+	}
+}